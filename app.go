@@ -7,30 +7,49 @@ import (
 	"github.com/up1io/muxo/middleware"
 	localMiddleware "github.com/up1io/muxo/module/local/middleware"
 	"github.com/up1io/muxo/runtime"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
+	"time"
 )
 
+// shutdownTimeout is the time Serve waits for the runtime to shut down after an interrupt.
+const shutdownTimeout = 10 * time.Second
+
 // App represents a web application with middleware support.
 type App struct {
 	srv         Server
 	runtime     runtime.Runtime
-	middlewares []middleware.Middleware
-	log         logger.Logger
+	middlewares *middleware.Chain
+	// replaced lists the middleware replaced by WithMiddleware or WithMiddlewareStack
+	replaced []string
+	log      logger.Logger
+	layout   Layout
 }
 
 // AppOption is a function that configures an App.
 type AppOption func(app *App)
 
-// NewApp creates a new App with the given options.
+// DefaultMiddleware returns the middleware stack of a new App: "request-id", "recover"
+// and "localization". Pass it to WithMiddlewareStack to extend it under the same names.
+func DefaultMiddleware() []middleware.Named {
+	return []middleware.Named{
+		middleware.Name("request-id", middleware.RequestID()),
+		middleware.Name("recover", middleware.Recover(middleware.RecoverConfig{Dev: runtime.IsDev()})),
+		middleware.Name("localization", localMiddleware.WithLocalization("web/locales")),
+	}
+}
+
+// NewApp creates a new App with the given options. Options changing the middleware stack
+// panic on conflicting or unknown middleware names, since the stack is fixed at startup.
 func NewApp(opts ...AppOption) *App {
 	app := &App{
 		runtime: runtime.NewDefaultRuntime(":8080"),
-		middlewares: []middleware.Middleware{
-			localMiddleware.WithLocalization("web/locales"),
-		},
-		log: logger.Default,
+		log:     logger.Default,
 	}
+	app.middlewares = mustChain(middleware.NewChain(DefaultMiddleware()...))
 
 	for _, opt := range opts {
 		opt(app)
@@ -55,18 +74,75 @@ func WithServer(srv Server) AppOption {
 
 // WithMiddleware allows users to override the default middleware stack.
 func WithMiddleware(middlewares ...middleware.Middleware) AppOption {
+	items := make([]middleware.Named, len(middlewares))
+	for i, mw := range middlewares {
+		items[i] = middleware.Unnamed(mw)
+	}
+	return WithMiddlewareStack(items...)
+}
+
+// WithMiddlewareStack overrides the default middleware stack like WithMiddleware, but keeps
+// the names of items, so later options can insert middleware around them.
+func WithMiddlewareStack(items ...middleware.Named) AppOption {
 	return func(app *App) {
-		app.middlewares = middlewares
+		app.replaced = append(app.replaced, app.middlewares.Names()...)
+		app.middlewares = mustChain(middleware.NewChain(items...))
 	}
 }
 
 // WithAdditionalMiddleware allows users to add middleware to the default stack.
 func WithAdditionalMiddleware(middlewares ...middleware.Middleware) AppOption {
+	items := make([]middleware.Named, len(middlewares))
+	for i, mw := range middlewares {
+		items[i] = middleware.Unnamed(mw)
+	}
+	return WithNamedMiddleware(items...)
+}
+
+// WithNamedMiddleware adds named middleware to the end of the stack, so later options can
+// insert middleware around them.
+func WithNamedMiddleware(items ...middleware.Named) AppOption {
+	return func(app *App) {
+		must(app.middlewares.Append(withOrigin(items, "appended")...))
+	}
+}
+
+// WithMiddlewareBefore inserts items in front of the middleware called name, e.g. "recover".
+func WithMiddlewareBefore(name string, items ...middleware.Named) AppOption {
+	return func(app *App) {
+		must(app.middlewares.InsertBefore(name, withOrigin(items, "inserted before "+name)...))
+	}
+}
+
+// WithMiddlewareAfter inserts items behind the middleware called name.
+func WithMiddlewareAfter(name string, items ...middleware.Named) AppOption {
 	return func(app *App) {
-		app.middlewares = append(app.middlewares, middlewares...)
+		must(app.middlewares.InsertAfter(name, withOrigin(items, "inserted after "+name)...))
 	}
 }
 
+// withOrigin returns copies of items with their Origin set to origin.
+func withOrigin(items []middleware.Named, origin string) []middleware.Named {
+	items = slices.Clone(items)
+	for i := range items {
+		items[i].Origin = origin
+	}
+	return items
+}
+
+// must panics on configuration errors of the middleware stack.
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// mustChain returns c or panics on err.
+func mustChain(c *middleware.Chain, err error) *middleware.Chain {
+	must(err)
+	return c
+}
+
 // WithLogger allows users to provide a custom logger.
 func WithLogger(log logger.Logger) AppOption {
 	return func(app *App) {
@@ -74,6 +150,24 @@ func WithLogger(log logger.Logger) AppOption {
 	}
 }
 
+// MiddlewareChain describes the resolved middleware chain, outermost first, noting which
+// middleware options appended or inserted and which WithMiddleware or WithMiddlewareStack
+// replaced.
+func (app *App) MiddlewareChain() string {
+	var b strings.Builder
+	for i, item := range app.middlewares.Items() {
+		fmt.Fprintf(&b, "%d. %s", i+1, item.Name)
+		if item.Origin != "" {
+			fmt.Fprintf(&b, " (%s)", item.Origin)
+		}
+		b.WriteString("\n")
+	}
+	if len(app.replaced) > 0 {
+		fmt.Fprintf(&b, "replaced by WithMiddleware: %s\n", strings.Join(app.replaced, ", "))
+	}
+	return b.String()
+}
+
 // Serve initializes and starts the server, applying middleware and handling graceful shutdown.
 func (app *App) Serve() error {
 	if app.srv == nil {
@@ -105,20 +199,42 @@ func (app *App) Serve() error {
 		// This uses the middleware stack configured in the App struct
 		// By default, this includes core modules like localization
 		// Users can override or add to this stack using WithMiddleware or WithAdditionalMiddleware
-		withMiddlewares := middleware.CreateStack(app.middlewares...)
-		handler := withMiddlewares(&mux)
+		app.log.Debug("middleware chain:\n%s", app.MiddlewareChain())
+		withMiddlewares := app.middlewares.Middleware()
+		handler := app.withContext(ctx, withMiddlewares(&mux))
 
 		if err := app.runtime.Serve(ctx, handler); err != nil {
 			app.log.Error("failed to run server: %s", err.Error())
 			errCh <- err
 			return
 		}
+		errCh <- nil
 	}()
 
 	select {
 	case <-ctx.Done():
+		// give the runtime time to drain open connections, e.g. streams ending on shutdown
+		select {
+		case <-errCh:
+		case <-time.After(shutdownTimeout):
+			app.log.Warn("server did not shut down within %s", shutdownTimeout)
+		}
 		return nil
 	case err := <-errCh:
 		return err
 	}
 }
+
+// withContext stores the app logger, the shutdown signal and the default layout in every request context.
+// Middleware such as RequestID and handlers derive request-scoped loggers through
+// logger.FromContext, long-running responses such as streams stop on ShutdownSignal.
+func (app *App) withContext(ctx context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqCtx := logger.NewContext(r.Context(), app.log)
+		reqCtx = NewShutdownContext(reqCtx, ctx.Done())
+		if app.layout != nil {
+			reqCtx = NewLayoutContext(reqCtx, app.layout)
+		}
+		next.ServeHTTP(w, r.WithContext(reqCtx))
+	})
+}
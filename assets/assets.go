@@ -0,0 +1,184 @@
+// Package assets serves static files from an fs.FS, embedded or on disk, under content
+// hashed names that can be cached forever.
+//
+//	//go:embed static
+//	var static embed.FS
+//
+//	sub, _ := fs.Sub(static, "static")
+//	srv, err := assets.New(sub, assets.Config{})
+//	assets.Default = srv
+//	mux.Handle("/assets/", srv)
+//
+// Templates link files with assets.URL("app.css"), which returns the fingerprinted URL,
+// e.g. "/assets/app.3f2a9c1be0.css".
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/up1io/muxo/runtime"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// DefaultPrefix is the URL path the assets are served under by default.
+const DefaultPrefix = "/assets/"
+
+// hashLength is the number of hex digits of the content hash in fingerprinted names.
+const hashLength = 10
+
+// Default is the Server used by URL. Set it once the assets are loaded.
+var Default *Server
+
+// Config configures a Server.
+type Config struct {
+	// Prefix is the URL path the Server is mounted at, defaults to DefaultPrefix
+	Prefix string
+	// Dev serves the files under their own names and reads them on every request, so
+	// changes show up without a restart. It is always enabled by `muxo dev`.
+	Dev bool
+}
+
+// asset is a file known to the Server.
+type asset struct {
+	name string
+	// hashed is the fingerprinted name
+	hashed string
+	etag   string
+}
+
+// Server serves the files of an fs.FS. Files are served under their fingerprinted names
+// with immutable cache headers and under their own names with revalidation.
+// A precompressed variant next to a file, e.g. app.css.br or app.css.gz, is served
+// instead of the file to clients accepting its encoding and not under its own name.
+type Server struct {
+	fsys   fs.FS
+	prefix string
+	dev    bool
+	// assets maps the file names to their assets
+	assets map[string]*asset
+	// hashed maps the fingerprinted names to their assets
+	hashed map[string]*asset
+}
+
+// New creates a Server for fsys, hashing all of its files. Outside dev mode, files added
+// to fsys later are not served.
+func New(fsys fs.FS, cfg Config) (*Server, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultPrefix
+	}
+	if !strings.HasSuffix(cfg.Prefix, "/") {
+		cfg.Prefix += "/"
+	}
+
+	s := &Server{
+		fsys:   fsys,
+		prefix: cfg.Prefix,
+		dev:    cfg.Dev || runtime.IsDev(),
+		assets: make(map[string]*asset),
+		hashed: make(map[string]*asset),
+	}
+	if s.dev {
+		return s, nil
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || s.isVariant(name) {
+			return nil
+		}
+
+		sum, err := hashFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		a := &asset{
+			name:   name,
+			hashed: fingerprint(name, sum[:hashLength]),
+			etag:   `"` + sum + `"`,
+		}
+		s.assets[name] = a
+		s.hashed[a.hashed] = a
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("assets: hash files: %w", err)
+	}
+
+	return s, nil
+}
+
+// URL returns the URL of the file name, fingerprinted unless in dev mode. Names of
+// unknown files are returned unhashed.
+func (s *Server) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if a, ok := s.assets[name]; ok {
+		return s.prefix + a.hashed
+	}
+	return s.prefix + name
+}
+
+// Manifest returns the fingerprinted names by file name. It is empty in dev mode.
+func (s *Server) Manifest() map[string]string {
+	manifest := make(map[string]string, len(s.assets))
+	for name, a := range s.assets {
+		manifest[name] = a.hashed
+	}
+	return manifest
+}
+
+// WriteManifest writes the manifest as JSON to w, e.g. for a build step or a CDN upload.
+func (s *Server) WriteManifest(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	// encoding/json sorts the keys, so the manifest is stable between builds
+	return enc.Encode(s.Manifest())
+}
+
+// URL returns the URL of the file name served by Default, see Server.URL.
+// Without a Default, it returns the name under DefaultPrefix.
+func URL(name string) string {
+	if Default == nil {
+		return DefaultPrefix + strings.TrimPrefix(name, "/")
+	}
+	return Default.URL(name)
+}
+
+// hashFile returns the hex encoded SHA-256 of the file name.
+func hashFile(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fingerprint inserts hash before the extension of name: "css/app.css" becomes
+// "css/app.<hash>.css".
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// isVariant reports whether name is a precompressed variant of another file. Files with
+// an encoding extension but no base file, e.g. a downloadable data.tar.gz, are assets.
+func (s *Server) isVariant(name string) bool {
+	ext := path.Ext(name)
+	if _, ok := encodingExtensions[ext]; !ok {
+		return false
+	}
+	info, err := fs.Stat(s.fsys, strings.TrimSuffix(name, ext))
+	return err == nil && !info.IsDir()
+}
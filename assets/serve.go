@@ -0,0 +1,174 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"github.com/up1io/muxo"
+	"github.com/up1io/muxo/logger"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// encodingExtensions maps the extensions of precompressed variants to their encodings.
+var encodingExtensions = map[string]string{
+	".br": "br",
+	".gz": "gzip",
+}
+
+// variantOrder lists the variant extensions in order of preference.
+var variantOrder = []string{".br", ".gz"}
+
+// immutable is the Cache-Control of fingerprinted files, whose content never changes.
+const immutable = "public, max-age=31536000, immutable"
+
+// ServeHTTP serves the file named by the request path below the prefix. It also works
+// behind http.StripPrefix.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		muxo.WriteError(w, r, muxo.NewHTTPError(http.StatusMethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, s.prefix)
+	name = strings.TrimPrefix(name, "/")
+	if name == "" || !fs.ValidPath(name) || s.isVariant(name) {
+		s.notFound(w, r)
+		return
+	}
+
+	h := w.Header()
+	etag := ""
+	if a, ok := s.hashed[name]; ok {
+		name, etag = a.name, a.etag
+		h.Set("Cache-Control", immutable)
+	} else if a, ok := s.assets[name]; ok {
+		etag = a.etag
+		h.Set("Cache-Control", "no-cache")
+	} else if s.dev {
+		h.Set("Cache-Control", "no-cache")
+	} else {
+		s.notFound(w, r)
+		return
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		s.openFailed(w, r, name, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		s.openFailed(w, r, name, err)
+		return
+	}
+	if info.IsDir() {
+		s.notFound(w, r)
+		return
+	}
+
+	ctype := mime.TypeByExtension(path.Ext(name))
+	h.Add("Vary", "Accept-Encoding")
+
+	// serve a precompressed variant if the client accepts its encoding
+	content := f
+	for _, ext := range variantOrder {
+		encoding := encodingExtensions[ext]
+		if !acceptsEncoding(r.Header.Get("Accept-Encoding"), encoding) {
+			continue
+		}
+		vf, err := s.fsys.Open(name + ext)
+		if err != nil {
+			continue
+		}
+		defer vf.Close()
+
+		content = vf
+		h.Set("Content-Encoding", encoding)
+		if etag != "" {
+			etag = strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+		}
+		if ctype == "" {
+			// sniffing the compressed bytes would yield a wrong type
+			ctype = "application/octet-stream"
+		}
+		break
+	}
+
+	if ctype != "" {
+		h.Set("Content-Type", ctype)
+	}
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+
+	rs, err := readSeeker(content)
+	if err != nil {
+		s.openFailed(w, r, name, err)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), rs)
+}
+
+// notFound writes a 404 response.
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Del("Cache-Control")
+	muxo.WriteError(w, r, muxo.NewHTTPError(http.StatusNotFound, "Not found", nil))
+}
+
+// openFailed writes the response for a file that could not be read.
+func (s *Server) openFailed(w http.ResponseWriter, r *http.Request, name string, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		s.notFound(w, r)
+		return
+	}
+	logger.FromContext(r.Context()).Error("assets: read %s: %s", name, err.Error())
+	w.Header().Del("Cache-Control")
+	muxo.WriteError(w, r, err)
+}
+
+// readSeeker returns f as an io.ReadSeeker for http.ServeContent. Files of embed.FS and
+// os.DirFS can seek, others are read into memory.
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header accepts encoding with a
+// non-zero q-value, either by name or through "*".
+func acceptsEncoding(accept, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encoding && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		if name == encoding {
+			// an explicit entry overrides "*"
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
@@ -0,0 +1,181 @@
+package muxo
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BindError is returned by Decode when request parameters cannot be converted
+// to the type of their struct field.
+type BindError struct {
+	// Fields maps parameter names to conversion problems
+	Fields map[string]string
+}
+
+// Error implements the error interface.
+func (e *BindError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("invalid parameters: %s", strings.Join(names, ", "))
+}
+
+// paramSources are the struct tags read by bindParams, in the order they are applied.
+var paramSources = []string{"path", "query", "header", "cookie"}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// bindParams fills the fields of v tagged with path, query, header or cookie from r.
+func bindParams(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	problems := make(map[string]string)
+	bindStruct(r, rv.Elem(), problems)
+
+	if len(problems) > 0 {
+		return &BindError{Fields: problems}
+	}
+	return nil
+}
+
+// bindStruct binds the tagged fields of rv, descending into embedded structs.
+func bindStruct(r *http.Request, rv reflect.Value, problems map[string]string) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			bindStruct(r, fv, problems)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		for _, source := range paramSources {
+			name, ok := field.Tag.Lookup(source)
+			if !ok || name == "" || name == "-" {
+				continue
+			}
+
+			values := paramValues(r, source, name)
+			if len(values) == 0 {
+				continue
+			}
+
+			if err := setField(fv, values); err != nil {
+				problems[name] = err.Error()
+			}
+		}
+	}
+}
+
+// paramValues returns the raw values of the named parameter from the given source.
+func paramValues(r *http.Request, source, name string) []string {
+	switch source {
+	case "path":
+		if v := r.PathValue(name); v != "" {
+			return []string{v}
+		}
+	case "query":
+		return r.URL.Query()[name]
+	case "header":
+		return r.Header.Values(name)
+	case "cookie":
+		if c, err := r.Cookie(name); err == nil {
+			return []string{c.Value}
+		}
+	}
+	return nil
+}
+
+// setField converts values to the type of fv and assigns them.
+func setField(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && !fv.Type().Implements(textUnmarshalerType) &&
+		!reflect.PointerTo(fv.Type()).Implements(textUnmarshalerType) {
+		out := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(out.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(out)
+		return nil
+	}
+
+	return setValue(fv, values[0])
+}
+
+// setValue converts s to the type of fv and assigns it.
+func setValue(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Pointer {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setValue(ptr.Elem(), s); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	if fv.CanAddr() {
+		if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(s)); err != nil {
+				return fmt.Errorf("invalid value %q", s)
+			}
+			return nil
+		}
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("must be a duration")
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a positive integer")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,317 @@
+// Package cache provides HTTP caching middleware: ETags with conditional requests and
+// a server-side response cache with TTLs, tag-based invalidation and pluggable stores.
+//
+//	responses := cache.New(cache.NewMemoryStore(10000, time.Minute))
+//	mux.Handle("GET /products", responses.For(5*time.Minute, "products")(listProducts))
+//	// after a product changed
+//	responses.Invalidate(ctx, "products")
+package cache
+
+import (
+	"context"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/middleware"
+	localMiddleware "github.com/up1io/muxo/module/local/middleware"
+	"github.com/up1io/muxo/session"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyFunc returns the part of the cache key that selects the variant of a response for r,
+// e.g. the ID of the signed in user. Returning false skips the cache for r.
+type KeyFunc func(r *http.Request) (string, bool)
+
+// Option configures a Cache.
+type Option func(c *Cache)
+
+// WithBypass adds a check that skips the cache for requests it returns true for. Use it
+// for authentication schemes that neither send cookies nor an Authorization header:
+//
+//	cache.WithBypass(func(r *http.Request) bool {
+//		_, ok := auth.CurrentUser(r.Context())
+//		return ok
+//	})
+func WithBypass(bypass func(r *http.Request) bool) Option {
+	return func(c *Cache) {
+		c.bypass = append(c.bypass, bypass)
+	}
+}
+
+// Cache caches responses in a Store.
+type Cache struct {
+	store       Store
+	maxBodySize int
+	bypass      []func(r *http.Request) bool
+}
+
+// New creates a Cache backed by store.
+func New(store Store, opts ...Option) *Cache {
+	c := &Cache{store: store, maxBodySize: DefaultMaxBodySize}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// tagSet collects the tags added by a handler while its response is cached.
+type tagSet struct {
+	mu   sync.Mutex
+	tags []string
+}
+
+type tagsKey struct{}
+
+// Tag adds tags to the response being cached for the request of ctx, in addition to the
+// tags of the middleware. Handlers use it to tag responses with the records they show,
+// e.g. "product:42". It does nothing if the response is not cached.
+func Tag(ctx context.Context, tags ...string) {
+	if s, ok := ctx.Value(tagsKey{}).(*tagSet); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.tags = append(s.tags, tags...)
+	}
+}
+
+// Invalidate removes all cached responses carrying one of tags.
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) error {
+	return c.store.InvalidateTags(ctx, tags...)
+}
+
+// For creates a middleware that caches successful GET and HEAD responses for ttl with
+// tags. Responses are keyed on the method, path, query, the language negotiated by the
+// localization middleware and the request headers listed in the response's Vary header.
+//
+// Only anonymous requests are cached: requests with a Cookie or Authorization header,
+// an active session or matching a WithBypass check skip the cache. Responses setting
+// cookies, marked private or no-store, and streamed responses are never stored.
+// Cached responses carry an ETag, so conditional requests are answered with 304.
+func (c *Cache) For(ttl time.Duration, tags ...string) middleware.Middleware {
+	return c.middleware(ttl, nil, tags)
+}
+
+// ForKey is like For, but caches personalized responses: key selects the variant for a
+// request, e.g. by user, and replaces the checks for cookies, sessions and credentials.
+// Responses setting cookies are still never stored.
+func (c *Cache) ForKey(ttl time.Duration, key KeyFunc, tags ...string) middleware.Middleware {
+	return c.middleware(ttl, key, tags)
+}
+
+// middleware creates the caching middleware, keyed by key if not nil.
+func (c *Cache) middleware(ttl time.Duration, key KeyFunc, tags []string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			primary := primaryKey(r)
+			if key != nil {
+				variant, ok := key(r)
+				if !ok {
+					next.ServeHTTP(w, r)
+					return
+				}
+				primary += "|key=" + variant
+			} else if c.personalized(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if entry, ok := c.lookup(r, primary); ok {
+				serveEntry(w, r, entry)
+				return
+			}
+
+			collected := &tagSet{tags: slices.Clone(tags)}
+			ctx := context.WithValue(r.Context(), tagsKey{}, collected)
+
+			rec := newRecorder(w, c.maxBodySize)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			if rec.streaming {
+				return
+			}
+
+			h := w.Header()
+			h.Set("X-Cache", "MISS")
+
+			vary, ok := cacheable(rec)
+			var entry *Entry
+			if ok {
+				if h.Get("ETag") == "" {
+					h.Set("ETag", computeETag(rec.body.Bytes(), false))
+				}
+
+				collected.mu.Lock()
+				entry = &Entry{
+					Status:   rec.status,
+					Header:   rec.ownHeader(),
+					Body:     slices.Clone(rec.body.Bytes()),
+					Vary:     vary,
+					Tags:     slices.Compact(slices.Sorted(slices.Values(collected.tags))),
+					StoredAt: time.Now(),
+				}
+				collected.mu.Unlock()
+				delete(entry.Header, "X-Cache")
+			}
+
+			if rec.status == http.StatusOK && notModified(r, h) {
+				writeNotModified(w)
+			} else {
+				_ = rec.send()
+			}
+
+			// outer middleware, e.g. the session, may only add cookies while the header is written
+			if entry == nil || len(h.Values("Set-Cookie")) > 0 || (key == nil && session.Active(r.Context())) {
+				return
+			}
+			c.save(r, primary, entry, ttl)
+		})
+	}
+}
+
+// personalized reports whether the response to r may depend on who sent it.
+func (c *Cache) personalized(r *http.Request) bool {
+	if r.Header.Get("Cookie") != "" || r.Header.Get("Authorization") != "" || session.Active(r.Context()) {
+		return true
+	}
+	return slices.ContainsFunc(c.bypass, func(bypass func(r *http.Request) bool) bool {
+		return bypass(r)
+	})
+}
+
+// save saves entry for the request r under primary, with an index of its vary headers.
+func (c *Cache) save(r *http.Request, primary string, entry *Entry, ttl time.Duration) {
+	log := logger.FromContext(r.Context())
+
+	if err := c.store.Set(r.Context(), variantKey(primary, entry.Vary, r), entry, ttl); err != nil {
+		log.Warn("cache: store %s: %s", primary, err.Error())
+		return
+	}
+	if len(entry.Vary) > 0 {
+		// the index tells later lookups which request headers select the variant
+		index := &Entry{Vary: entry.Vary, Tags: entry.Tags}
+		if err := c.store.Set(r.Context(), primary, index, ttl); err != nil {
+			log.Warn("cache: store %s: %s", primary, err.Error())
+		}
+	}
+}
+
+// lookup returns the cached response for r.
+func (c *Cache) lookup(r *http.Request, primary string) (*Entry, bool) {
+	log := logger.FromContext(r.Context())
+
+	index, ok, err := c.store.Get(r.Context(), primary)
+	if err != nil {
+		log.Warn("cache: lookup %s: %s", primary, err.Error())
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	if index.Status != 0 {
+		// the response does not vary, the primary key holds the response itself
+		return index, true
+	}
+
+	key := variantKey(primary, index.Vary, r)
+	entry, ok, err := c.store.Get(r.Context(), key)
+	if err != nil {
+		log.Warn("cache: lookup %s: %s", key, err.Error())
+		return nil, false
+	}
+	return entry, ok && entry.Status != 0
+}
+
+// serveEntry writes a cached response, or 304 if the client's copy is still valid.
+func serveEntry(w http.ResponseWriter, r *http.Request, e *Entry) {
+	h := w.Header()
+	for k, v := range e.Header {
+		h[k] = slices.Clone(v)
+	}
+	h.Set("X-Cache", "HIT")
+	h.Set("Age", strconv.Itoa(int(time.Since(e.StoredAt).Seconds())))
+
+	if e.Status == http.StatusOK && notModified(r, h) {
+		writeNotModified(w)
+		return
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(e.Body)
+	}
+}
+
+// cacheable reports whether the recorded response may be stored and returns the request
+// headers it varies on.
+func cacheable(rec *recorder) ([]string, bool) {
+	if rec.status != http.StatusOK || rec.body.Len() == 0 {
+		return nil, false
+	}
+
+	h := rec.Header()
+	if len(h.Values("Set-Cookie")) > 0 {
+		return nil, false
+	}
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return nil, false
+	}
+
+	var vary []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				vary = append(vary, name)
+			}
+		}
+	}
+	slices.Sort(vary)
+	return slices.Compact(vary), true
+}
+
+// primaryKey returns the cache key of r without the Vary headers.
+func primaryKey(r *http.Request) string {
+	var b strings.Builder
+	// HEAD is answered from the GET response
+	b.WriteString("GET ")
+	b.WriteString(r.Host)
+	b.WriteString(r.URL.Path)
+	if q := r.URL.Query(); len(q) > 0 {
+		// Encode sorts the parameters, so their order does not matter
+		b.WriteString("?")
+		b.WriteString(q.Encode())
+	}
+	if lang, ok := localMiddleware.LanguageFromContext(r.Context()); ok {
+		b.WriteString("|lang=")
+		b.WriteString(lang)
+	}
+	return b.String()
+}
+
+// variantKey returns the key of the variant of primary selected by the vary headers of r.
+func variantKey(primary string, vary []string, r *http.Request) string {
+	if len(vary) == 0 {
+		return primary
+	}
+
+	var b strings.Builder
+	b.WriteString(primary)
+	for _, name := range vary {
+		b.WriteString("|")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
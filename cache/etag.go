@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/up1io/muxo/middleware"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBodySize is the largest response body buffered for ETags and caching.
+// Larger responses are streamed unchanged.
+const DefaultMaxBodySize = 1 << 20
+
+// ETagConfig configures the ETag middleware.
+type ETagConfig struct {
+	// Weak generates weak ETags, which only promise semantic equivalence. Use it when the
+	// body may be transformed on the way, e.g. by compression in a proxy.
+	Weak bool
+	// MaxBodySize defaults to DefaultMaxBodySize
+	MaxBodySize int
+}
+
+// ETag creates a middleware that buffers successful GET and HEAD responses, sets an ETag
+// computed from the body unless the handler set one, and answers conditional requests
+// with If-None-Match or If-Modified-Since with 304 Not Modified.
+// Streamed responses, which flush, are passed through without ETag.
+func ETag(cfg ETagConfig) middleware.Middleware {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := newRecorder(w, cfg.MaxBodySize)
+			next.ServeHTTP(rec, r)
+			if rec.streaming {
+				return
+			}
+
+			if rec.status != http.StatusOK {
+				_ = rec.send()
+				return
+			}
+
+			h := w.Header()
+			// handlers may skip the body of HEAD requests, which would yield a different tag
+			if h.Get("ETag") == "" && (r.Method == http.MethodGet || rec.body.Len() > 0) {
+				h.Set("ETag", computeETag(rec.body.Bytes(), cfg.Weak))
+			}
+
+			if notModified(r, h) {
+				writeNotModified(w)
+				return
+			}
+			_ = rec.send()
+		})
+	}
+}
+
+// computeETag returns an entity tag for body.
+func computeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// notModified evaluates the conditional headers of r against the response header h
+// following RFC 9110: If-None-Match takes precedence over If-Modified-Since.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// If-None-Match uses the weak comparison
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	lm := h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// writeNotModified writes a 304 response, keeping the validators and caching headers
+// but dropping the body related fields.
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+)
+
+// recorder buffers a response so it can be hashed or stored before it is sent.
+// Responses that flush or outgrow the limit are streamed instead and not buffered.
+type recorder struct {
+	http.ResponseWriter
+	// before is the header set by outer handlers, used to tell which headers the
+	// wrapped handler set
+	before      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int
+	// streaming is set once the buffered response was sent and writes pass through
+	streaming bool
+}
+
+// newRecorder wraps w, buffering at most limit bytes.
+func newRecorder(w http.ResponseWriter, limit int) *recorder {
+	return &recorder{
+		ResponseWriter: w,
+		before:         w.Header().Clone(),
+		status:         http.StatusOK,
+		limit:          limit,
+	}
+}
+
+func (w *recorder) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	w.wroteHeader = true
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.body.Len()+len(b) > w.limit {
+		if err := w.stream(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+// Flush switches to streaming, a flushed response can't be buffered.
+func (w *recorder) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is the Flush used by http.ResponseController, it reports errors.
+func (w *recorder) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := w.stream(); err != nil {
+		return err
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (w *recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// stream sends the buffered response and passes later writes through.
+func (w *recorder) stream() error {
+	if w.streaming {
+		return nil
+	}
+	w.streaming = true
+	return w.send()
+}
+
+// send writes the recorded status and body.
+func (w *recorder) send() error {
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	return err
+}
+
+// ownHeader returns the header fields set or changed by the wrapped handler.
+func (w *recorder) ownHeader() http.Header {
+	own := make(http.Header)
+	for k, v := range w.Header() {
+		if !slices.Equal(w.before[k], v) {
+			own[k] = slices.Clone(v)
+		}
+	}
+	return own
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry is a cached response.
+type Entry struct {
+	Status int
+	// Header holds the header fields set by the cached handler
+	Header http.Header
+	Body   []byte
+	// Vary lists the request headers the response varies on
+	Vary []string
+	Tags []string
+	// StoredAt is used for the Age header
+	StoredAt time.Time
+}
+
+// Store keeps cached responses. Implement it to share the cache between instances,
+// e.g. with Redis.
+type Store interface {
+	// Get returns the entry stored for key, false if there is none or it expired
+	Get(ctx context.Context, key string) (*Entry, bool, error)
+	// Set stores e for key for ttl
+	Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error
+	// InvalidateTags removes all entries carrying one of tags
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// memoryEntry is an entry kept by the MemoryStore.
+type memoryEntry struct {
+	key     string
+	entry   *Entry
+	expires time.Time
+}
+
+// MemoryStore keeps cached responses in process memory, evicting expired entries
+// periodically and the least recently used entries once maxEntries is reached.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru orders the entries by last use, most recent first
+	lru        *list.List
+	tags       map[string]map[string]struct{}
+	maxEntries int
+	stop       chan struct{}
+	once       sync.Once
+}
+
+// NewMemoryStore creates a MemoryStore holding at most maxEntries entries, zero means no
+// limit. Expired entries are evicted every cleanupInterval, a zero interval disables it.
+func NewMemoryStore(maxEntries int, cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		tags:       make(map[string]map[string]struct{}),
+		maxEntries: maxEntries,
+		stop:       make(chan struct{}),
+	}
+
+	if cleanupInterval > 0 {
+		go s.cleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryEntry)
+	if time.Now().After(e.expires) {
+		s.delete(key)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(el)
+	return e.entry, true, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(ctx context.Context, key string, e *Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(key)
+	if s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		s.evictLeastRecent()
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryEntry{key: key, entry: e, expires: time.Now().Add(ttl)})
+	for _, tag := range e.Tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return nil
+}
+
+// InvalidateTags implements Store.
+func (s *MemoryStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.delete(key)
+		}
+		delete(s.tags, tag)
+	}
+	return nil
+}
+
+// Close stops the background eviction.
+func (s *MemoryStore) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// delete removes key and its tag references. s.mu must be held.
+func (s *MemoryStore) delete(key string) {
+	el, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	delete(s.entries, key)
+	e := s.lru.Remove(el).(*memoryEntry)
+
+	for _, tag := range e.entry.Tags {
+		if keys, ok := s.tags[tag]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
+
+// evictLeastRecent removes the least recently used entry. s.mu must be held.
+func (s *MemoryStore) evictLeastRecent() {
+	if el := s.lru.Back(); el != nil {
+		s.delete(el.Value.(*memoryEntry).key)
+	}
+}
+
+// cleanup evicts expired entries every interval until Close is called.
+func (s *MemoryStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for key, el := range s.entries {
+				if now.After(el.Value.(*memoryEntry).expires) {
+					s.delete(key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/up1io/muxo/locales"
 	"github.com/up1io/muxo/logger"
 	"github.com/up1io/muxo/processor"
+	"github.com/up1io/muxo/runtime"
 	"github.com/up1io/muxo/templater"
 	"github.com/up1io/muxo/watcher"
 	"log"
@@ -162,6 +163,7 @@ func supervise(restart <-chan struct{}) {
 // runApp starts the Go application and returns the *exec.Cmd
 func runApp() *exec.Cmd {
 	cmd := exec.Command("go", "run", "cmd/local/main.go")
+	cmd.Env = append(os.Environ(), runtime.EnvKey+"="+runtime.EnvDev)
 
 	// ensure subprocesses die with parent
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -0,0 +1,149 @@
+// Package codec provides a registry of body encoders and decoders selected by media type.
+package codec
+
+import (
+	"errors"
+	"github.com/up1io/muxo/render"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrNotAcceptable is returned when no registered codec matches the Accept header.
+var ErrNotAcceptable = errors.New("codec: no acceptable content type")
+
+// Codec encodes and decodes values for a single media type.
+type Codec interface {
+	// ContentType returns the media type written to the Content-Type header.
+	ContentType() string
+	// Encode writes v to w.
+	Encode(w io.Writer, v any) error
+	// Decode reads r into v.
+	Decode(r io.Reader, v any) error
+}
+
+// Registry holds codecs in server preference order.
+type Registry struct {
+	mu     sync.RWMutex
+	codecs []Codec
+	// decoders are only used to decode request bodies, never to encode responses
+	decoders []Codec
+}
+
+// NewRegistry creates a Registry with the given codecs, the first one is preferred.
+func NewRegistry(codecs ...Codec) *Registry {
+	return &Registry{codecs: codecs}
+}
+
+// Default is the registry used by muxo.Respond and muxo.Decode. Registering a codec for
+// one of the built-in media types, e.g. application/json, replaces the built-in for both.
+// Form is only registered for decoding.
+var Default = newDefault()
+
+// newDefault creates the Default registry.
+func newDefault() *Registry {
+	reg := NewRegistry(JSON{}, XML{}, MsgPack{}, CBOR{})
+	reg.RegisterDecoder(Form{})
+	return reg
+}
+
+// Register adds c to the registry, replacing a codec with the same media type.
+func (reg *Registry) Register(c Codec) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.decoders = without(reg.decoders, c)
+	reg.codecs = replace(reg.codecs, c)
+}
+
+// RegisterDecoder adds c to the registry for decoding only, so it is never negotiated for
+// a response. It replaces a codec with the same media type.
+func (reg *Registry) RegisterDecoder(c Codec) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	reg.codecs = without(reg.codecs, c)
+	reg.decoders = replace(reg.decoders, c)
+}
+
+// replace replaces the codec with the media type of c in codecs, or appends c.
+func replace(codecs []Codec, c Codec) []Codec {
+	want := mediaType(c.ContentType())
+	for i, existing := range codecs {
+		if mediaType(existing.ContentType()) == want {
+			codecs[i] = c
+			return codecs
+		}
+	}
+	return append(codecs, c)
+}
+
+// without removes the codec with the media type of c from codecs.
+func without(codecs []Codec, c Codec) []Codec {
+	want := mediaType(c.ContentType())
+	return slices.DeleteFunc(codecs, func(existing Codec) bool {
+		return mediaType(existing.ContentType()) == want
+	})
+}
+
+// ContentTypes returns the media types of the codecs for responses in preference order.
+func (reg *Registry) ContentTypes() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	out := make([]string, 0, len(reg.codecs))
+	for _, c := range reg.codecs {
+		out = append(out, mediaType(c.ContentType()))
+	}
+	return out
+}
+
+// DecodeTypes returns the media types of all codecs, including those only registered
+// for decoding.
+func (reg *Registry) DecodeTypes() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	out := make([]string, 0, len(reg.codecs)+len(reg.decoders))
+	for _, c := range slices.Concat(reg.codecs, reg.decoders) {
+		out = append(out, mediaType(c.ContentType()))
+	}
+	return out
+}
+
+// Negotiate returns the codec that best matches the Accept header.
+func (reg *Registry) Negotiate(accept string) (Codec, error) {
+	offer, ok := render.Negotiate(accept, reg.ContentTypes()...)
+	if !ok {
+		return nil, ErrNotAcceptable
+	}
+
+	c, _ := reg.Lookup(offer)
+	return c, nil
+}
+
+// Lookup returns the codec for the media type of a Content-Type header, including codecs
+// only registered for decoding.
+func (reg *Registry) Lookup(contentType string) (Codec, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	want := mediaType(contentType)
+	for _, c := range slices.Concat(reg.codecs, reg.decoders) {
+		if mediaType(c.ContentType()) == want {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// mediaType returns the lower-cased media type of a Content-Type value without parameters.
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/schema"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"net/url"
+)
+
+// JSON encodes values as application/json.
+type JSON struct{}
+
+// ContentType implements Codec.
+func (JSON) ContentType() string { return "application/json; charset=utf-8" }
+
+// Encode implements Codec.
+func (JSON) Encode(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+
+// Decode implements Codec.
+func (JSON) Decode(r io.Reader, v any) error { return json.NewDecoder(r).Decode(v) }
+
+// XML encodes values as application/xml.
+type XML struct{}
+
+// ContentType implements Codec.
+func (XML) ContentType() string { return "application/xml; charset=utf-8" }
+
+// Encode implements Codec.
+func (XML) Encode(w io.Writer, v any) error { return xml.NewEncoder(w).Encode(v) }
+
+// Decode implements Codec.
+func (XML) Decode(r io.Reader, v any) error { return xml.NewDecoder(r).Decode(v) }
+
+// MsgPack encodes values as application/msgpack.
+type MsgPack struct{}
+
+// ContentType implements Codec.
+func (MsgPack) ContentType() string { return "application/msgpack" }
+
+// Encode implements Codec.
+func (MsgPack) Encode(w io.Writer, v any) error { return msgpack.NewEncoder(w).Encode(v) }
+
+// Decode implements Codec.
+func (MsgPack) Decode(r io.Reader, v any) error { return msgpack.NewDecoder(r).Decode(v) }
+
+// CBOR encodes values as application/cbor.
+type CBOR struct{}
+
+// ContentType implements Codec.
+func (CBOR) ContentType() string { return "application/cbor" }
+
+// Encode implements Codec.
+func (CBOR) Encode(w io.Writer, v any) error { return cbor.NewEncoder(w).Encode(v) }
+
+// Decode implements Codec.
+func (CBOR) Decode(r io.Reader, v any) error { return cbor.NewDecoder(r).Decode(v) }
+
+// Form encodes structs as application/x-www-form-urlencoded using their schema tags.
+type Form struct{}
+
+// ContentType implements Codec.
+func (Form) ContentType() string { return "application/x-www-form-urlencoded" }
+
+// Encode implements Codec.
+func (Form) Encode(w io.Writer, v any) error {
+	values := url.Values{}
+	if err := schema.NewEncoder().Encode(v, values); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, values.Encode())
+	return err
+}
+
+// Decode implements Codec.
+func (Form) Decode(r io.Reader, v any) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return err
+	}
+	return schema.NewDecoder().Decode(v, values)
+}
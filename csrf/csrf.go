@@ -0,0 +1,358 @@
+// Package csrf protects forms and unsafe requests against cross-site request forgery
+// using signed double-submit cookies.
+//
+// The middleware sets a signed random secret in a cookie. Unsafe requests (POST, PUT, PATCH,
+// DELETE, ...) must echo a token derived from it in the csrf_token form field or the
+// X-CSRF-Token header, which a cross-site attacker can't read. Forms render the field with
+// Field, htmx requests send the header configured with HXHeaders:
+//
+//	<form method="post">
+//		@csrf.Field()
+//	</form>
+//	<body hx-headers={ csrf.HXHeaders(ctx) }>
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/up1io/muxo"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/middleware"
+	"github.com/up1io/muxo/render"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	// FieldName is the name of the form field carrying the token.
+	FieldName = "csrf_token"
+	// HeaderName is the request header carrying the token.
+	HeaderName = "X-CSRF-Token"
+	// CookieName is the default name of the cookie holding the secret.
+	CookieName = "csrf"
+)
+
+func init() {
+	// the token is checked here, form decoders must not reject it as an unknown field
+	muxo.SkipFormFields(FieldName)
+}
+
+// secretSize is the number of random bytes of a secret.
+const secretSize = 32
+
+// Errors reported when a request is rejected.
+var (
+	ErrNoCookie     = errors.New("csrf: missing or invalid cookie")
+	ErrNoToken      = errors.New("csrf: missing token")
+	ErrInvalidToken = errors.New("csrf: invalid token")
+	ErrBadOrigin    = errors.New("csrf: origin not allowed")
+)
+
+// Config configures the Protect middleware.
+type Config struct {
+	// Key signs the secret cookie, it should contain at least 32 random bytes
+	Key []byte
+	// CookieName defaults to CookieName
+	CookieName string
+	// Path of the cookie, defaults to "/"
+	Path string
+	// Domain of the cookie
+	Domain string
+	// Secure restricts the cookie to HTTPS
+	Secure bool
+	// SameSite defaults to http.SameSiteLaxMode
+	SameSite http.SameSite
+	// TrustedOrigins lists hosts besides the request host allowed in the Origin header of unsafe requests
+	TrustedOrigins []string
+	// ErrorHandler writes the response for rejected requests, defaults to a 403 error.
+	// The reason is available through Reason.
+	ErrorHandler http.Handler
+}
+
+type key int
+
+const (
+	tokenKey key = iota
+	reasonKey
+)
+
+// Protect creates a middleware that rejects unsafe requests without a valid token.
+func Protect(cfg Config) middleware.Middleware {
+	if len(cfg.Key) == 0 {
+		panic("csrf: Config.Key is required")
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = CookieName
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = http.HandlerFunc(forbidden)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret, ok := cfg.readSecret(r)
+			if !ok {
+				var err error
+				if secret, err = newSecret(); err != nil {
+					logger.FromContext(r.Context()).Error("%v", err)
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				cfg.writeSecret(w, secret)
+			}
+
+			// the token is derived lazily, only pages rendering it pay for the masking
+			ctx := context.WithValue(r.Context(), tokenKey, func() string { return maskToken(secret) })
+			r = r.WithContext(ctx)
+			w.Header().Add("Vary", "Cookie")
+
+			if !isSafe(r.Method) {
+				var reason error
+				switch {
+				case !cfg.allowedOrigin(r):
+					reason = ErrBadOrigin
+				case !ok:
+					reason = ErrNoCookie
+				default:
+					reason = verify(r, secret)
+				}
+
+				if reason != nil {
+					logger.FromContext(r.Context()).Debug("csrf: rejected %s %s: %v", r.Method, r.URL.Path, reason)
+					cfg.ErrorHandler.ServeHTTP(w, r.WithContext(context.WithValue(ctx, reasonKey, reason)))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Token returns a token for the request of ctx. Each call returns a differently masked
+// token of the same secret, which prevents compression side channels like BREACH.
+func Token(ctx context.Context) string {
+	if f, ok := ctx.Value(tokenKey).(func() string); ok {
+		return f()
+	}
+	return ""
+}
+
+// Reason returns why the request of ctx was rejected, inside a Config.ErrorHandler.
+func Reason(ctx context.Context) error {
+	err, _ := ctx.Value(reasonKey).(error)
+	return err
+}
+
+// Field renders the hidden input carrying the token in a form. Render it before file
+// inputs, the middleware only searches the first 64 KiB of a form body for the token.
+func Field() templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := fmt.Fprintf(w, `<input type="hidden" name="%s" value="%s">`, FieldName, templ.EscapeString(Token(ctx)))
+		return err
+	})
+}
+
+// HXHeaders returns the value for an hx-headers attribute, so htmx sends the token
+// in the X-CSRF-Token header with every request.
+func HXHeaders(ctx context.Context) string {
+	b, _ := json.Marshal(map[string]string{HeaderName: Token(ctx)})
+	return string(b)
+}
+
+// isSafe reports whether method is safe according to RFC 9110 and needs no token.
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// verify checks the token of r against secret. The header takes precedence over the form.
+func verify(r *http.Request, secret []byte) error {
+	token := r.Header.Get(HeaderName)
+	if token == "" {
+		token = formToken(r)
+	}
+	if token == "" {
+		return ErrNoToken
+	}
+
+	if !hmac.Equal(unmaskToken(token), secret) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// maxFormScan is the number of body bytes searched for the token field, so the token is
+// found without reading large uploads.
+const maxFormScan = 64 << 10
+
+// formToken returns the token field of a form body. It reads at most maxFormScan bytes
+// and puts them back in front of the body, so the handler parses the whole form within
+// its own limits.
+func formToken(r *http.Request) string {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data") {
+		return ""
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, maxFormScan))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		// a truncated last pair is dropped, the others are still parsed
+		values, _ := url.ParseQuery(string(buf))
+		return values.Get(FieldName)
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(buf), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return ""
+		}
+		if part.FormName() == FieldName && part.FileName() == "" {
+			token, _ := io.ReadAll(io.LimitReader(part, 1024))
+			return string(token)
+		}
+	}
+}
+
+// allowedOrigin checks the Origin header of r, if browsers sent one.
+func (cfg Config) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || slices.ContainsFunc(cfg.TrustedOrigins, func(o string) bool {
+		return strings.EqualFold(o, u.Host)
+	})
+}
+
+// readSecret returns the secret of the cookie of r if its signature is valid.
+func (cfg Config) readSecret(r *http.Request) ([]byte, bool) {
+	c, err := r.Cookie(cfg.CookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil || len(b) != secretSize+sha256.Size {
+		return nil, false
+	}
+
+	secret, sig := b[:secretSize], b[secretSize:]
+	if !hmac.Equal(sig, cfg.sign(secret)) {
+		return nil, false
+	}
+	return secret, true
+}
+
+// writeSecret sets the signed secret cookie.
+func (cfg Config) writeSecret(w http.ResponseWriter, secret []byte) {
+	value := append(append([]byte{}, secret...), cfg.sign(secret)...)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: cfg.SameSite,
+	})
+}
+
+// sign returns the HMAC of secret.
+func (cfg Config) sign(secret []byte) []byte {
+	mac := hmac.New(sha256.New, cfg.Key)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+// newSecret returns a random secret.
+func newSecret() ([]byte, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("csrf: secret: %w", err)
+	}
+	return b, nil
+}
+
+// maskToken returns secret XORed with a random one-time pad, prefixed with the pad.
+func maskToken(secret []byte) string {
+	b := make([]byte, 2*secretSize)
+	pad := b[:secretSize]
+	if _, err := rand.Read(pad); err != nil {
+		// rand.Read never fails on supported platforms
+		panic(err)
+	}
+	subtle.XORBytes(b[secretSize:], secret, pad)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// unmaskToken returns the secret of a masked token, or nil if the token is malformed.
+func unmaskToken(token string) []byte {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) != 2*secretSize {
+		return nil
+	}
+	secret := make([]byte, secretSize)
+	subtle.XORBytes(secret, b[secretSize:], b[:secretSize])
+	return secret
+}
+
+// forbidden writes the default 403 response for rejected requests.
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusForbidden
+	message := "The request could not be verified. Please reload the page and try again."
+	log := logger.FromContext(r.Context())
+
+	if render.PrefersJSON(r) {
+		problem := render.NewProblem(status, message)
+		problem.Instance = r.URL.Path
+		if id, ok := middleware.RequestIDFromContext(r.Context()); ok {
+			problem.With("request_id", id)
+		}
+		if err := render.ProblemJSON(w, problem); err != nil {
+			log.Error("failed to write csrf response: %s", err.Error())
+		}
+		return
+	}
+
+	if err := render.HTML(w, r, status, render.ErrorPage(status, message)); err != nil {
+		log.Error("failed to write csrf response: %s", err.Error())
+	}
+}
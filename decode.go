@@ -2,52 +2,357 @@ package muxo
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/schema"
+	"github.com/up1io/muxo/codec"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/validation"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 )
 
 var decoder = schema.NewDecoder()
 
-func Decode[T any](r *http.Request) (T, error) {
+// DecodeError is returned by Decode when the request cannot be decoded.
+// Status is 413 for bodies or files exceeding their limit, 415 for unsupported
+// content types and 400 for any other malformed request.
+type DecodeError struct {
+	Status int
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Detail returns a description of the error that is safe to show to clients.
+// The underlying error may contain parts of the request and is only logged.
+func (e *DecodeError) Detail() string {
+	switch e.Status {
+	case http.StatusRequestEntityTooLarge:
+		return "The request body is too large."
+	case http.StatusUnsupportedMediaType:
+		return "The content type of the request is not supported."
+	default:
+		return "The request body could not be decoded."
+	}
+}
+
+// newDecodeError wraps err in a DecodeError with the status derived from err.
+func newDecodeError(err error) *DecodeError {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return &DecodeError{Status: decodeErr.Status, Err: err}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &DecodeError{Status: http.StatusRequestEntityTooLarge, Err: err}
+	}
+
+	return &DecodeError{Status: http.StatusBadRequest, Err: err}
+}
+
+func Decode[T any](r *http.Request, opts ...DecodeOption) (T, error) {
+	v, err := decode[T](r, newDecodeConfig(opts))
+	if err != nil {
+		logger.FromContext(r.Context()).Warn("%s %s: %s", r.Method, r.URL.Path, err.Error())
+		return v, newDecodeError(err)
+	}
+	return v, nil
+}
+
+func decode[T any](r *http.Request, cfg *decodeConfig) (T, error) {
 	var v T
+
+	if err := decodeBody(r, &v, cfg); err != nil {
+		return v, err
+	}
+	if err := bindParams(r, &v); err != nil {
+		return v, err
+	}
+
+	return v, nil
+}
+
+// decodeBody decodes the request body into v based on its content type. Multipart forms
+// are decoded from the request, any other body by its codec in codec.Default.
+// Requests without body and content type, e.g. GET requests, are skipped.
+func decodeBody(r *http.Request, v interface{}, cfg *decodeConfig) error {
 	typ := r.Header.Get("Content-Type")
 
-	if strings.HasPrefix(typ, "application/x-www-form-urlencoded") {
-		if err := DecodeForm(r, &v); err != nil {
-			return v, fmt.Errorf("decode form data: %w", err)
+	if typ == "" && (r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0) {
+		return nil
+	}
+
+	if cfg.maxBodySize > 0 {
+		r.Body = http.MaxBytesReader(cfg.w, r.Body, cfg.maxBodySize)
+	}
+
+	if matchContentType(typ, "multipart/form-data", cfg) {
+		if err := decodeMultipart(r, v, cfg); err != nil {
+			return fmt.Errorf("decode multipart form: %w", err)
+		}
+		return nil
+	}
+
+	c, ok := lookupCodec(typ, cfg)
+	if !ok {
+		return &DecodeError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    fmt.Errorf("content type %s is not supported", typ),
+		}
+	}
+
+	// the built-in codecs are decoded from the request, so the decode options and
+	// SkipFormFields apply; codecs registered in their place decode the body as they are
+	switch c.(type) {
+	case codec.JSON:
+		if err := decodeJSON(r.Body, v, cfg); err != nil {
+			return fmt.Errorf("decode json: %w", err)
+		}
+	case codec.Form:
+		if err := DecodeForm(r, v); err != nil {
+			return fmt.Errorf("decode form data: %w", err)
+		}
+	default:
+		if err := c.Decode(r.Body, v); err != nil {
+			return fmt.Errorf("decode %s: %w", typ, err)
+		}
+	}
+	return nil
+}
+
+// lookupCodec returns the codec of codec.Default for the Content-Type header typ. Unless
+// the content type is strict, a header starting with the media type of a codec matches it.
+func lookupCodec(typ string, cfg *decodeConfig) (codec.Codec, bool) {
+	if !cfg.strictContentType {
+		if c, ok := codec.Default.Lookup(typ); ok {
+			return c, true
 		}
-		return v, nil
 	}
 
-	if strings.HasPrefix(typ, "application/json") {
-		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-			return v, fmt.Errorf("decode json: %w", err)
+	for _, want := range codec.Default.DecodeTypes() {
+		if matchContentType(typ, want, cfg) {
+			return codec.Default.Lookup(want)
 		}
-		return v, nil
+	}
+	return nil, false
+}
+
+// matchContentType reports whether the Content-Type header typ denotes the media type want.
+func matchContentType(typ, want string, cfg *decodeConfig) bool {
+	if !cfg.strictContentType {
+		return strings.HasPrefix(typ, want)
 	}
 
-	return v, fmt.Errorf("content type %s is not supported", typ)
+	mediaType, params, err := mime.ParseMediaType(typ)
+	if err != nil || mediaType != want {
+		return false
+	}
+	charset, ok := params["charset"]
+	return !ok || strings.EqualFold(charset, "utf-8")
+}
+
+// decodeJSON decodes a JSON body into v applying the strict options of cfg.
+func decodeJSON(body io.Reader, v interface{}, cfg *decodeConfig) error {
+	dec := json.NewDecoder(body)
+	if cfg.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+
+	if cfg.singleValue {
+		if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return err
+			}
+			return errors.New("body must only contain a single JSON value")
+		}
+	}
+
+	return nil
 }
 
 func DecodeForm(r *http.Request, v interface{}) error {
 	if err := r.ParseForm(); err != nil {
 		return err
 	}
-	if err := decoder.Decode(v, r.PostForm); err != nil {
+	if err := decoder.Decode(v, withoutSkipped(r.PostForm)); err != nil {
 		return err
 	}
 	return nil
 }
 
-func DecodeValid[T Validator](r *http.Request) (T, map[string]string, error) {
-	v, err := Decode[T](r)
+// skippedFields holds the form fields registered with SkipFormFields.
+var skippedFields = struct {
+	sync.RWMutex
+	names map[string]struct{}
+}{names: make(map[string]struct{})}
+
+// SkipFormFields makes the form decoders ignore the fields names, which would otherwise
+// be rejected as unknown keys. Middleware reading its own form fields, like the csrf
+// token, registers them in an init function.
+func SkipFormFields(names ...string) {
+	skippedFields.Lock()
+	defer skippedFields.Unlock()
+	for _, name := range names {
+		skippedFields.names[name] = struct{}{}
+	}
+}
+
+// withoutSkipped returns values without the fields registered with SkipFormFields.
+func withoutSkipped(values map[string][]string) map[string][]string {
+	skippedFields.RLock()
+	defer skippedFields.RUnlock()
+
+	stripped := values
+	copied := false
+	for name := range skippedFields.names {
+		if _, ok := values[name]; !ok {
+			continue
+		}
+		// the parsed form belongs to the request, strip a copy
+		if !copied {
+			stripped, copied = maps.Clone(values), true
+		}
+		delete(stripped, name)
+	}
+	return stripped
+}
+
+// decodeMultipart parses a multipart form and decodes its values and files into v.
+func decodeMultipart(r *http.Request, v interface{}, cfg *decodeConfig) error {
+	if err := parseMultipart(r, cfg); err != nil {
+		return err
+	}
+	if err := decoder.Decode(v, withoutSkipped(r.MultipartForm.Value)); err != nil {
+		return err
+	}
+	return decodeFiles(v, r.MultipartForm.File, cfg.maxFileSize)
+}
+
+// parseMultipart parses the multipart form of r like ParseMultipartForm. With a maximum
+// file size the parts are streamed, so a larger file fails before it is buffered.
+func parseMultipart(r *http.Request, cfg *decodeConfig) error {
+	if cfg.maxFileSize <= 0 || r.MultipartForm != nil {
+		return r.ParseMultipartForm(cfg.maxMemory)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	mr, err := r.MultipartReader()
 	if err != nil {
-		return v, nil, fmt.Errorf("decode json: %w", err)
+		return err
 	}
-	if problems := v.Valid(r.Context()); len(problems) > 0 {
-		return v, problems, fmt.Errorf("invalid %T: %d problems", v, len(problems))
+
+	pr, pw := io.Pipe()
+	// unblocks copyParts if ReadForm stops early
+	defer pr.Close()
+
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(copyParts(mw, mr, cfg.maxFileSize))
+	}()
+
+	form, err := multipart.NewReader(pr, mw.Boundary()).ReadForm(cfg.maxMemory)
+	if err != nil {
+		return err
+	}
+
+	// merge the values the way ParseMultipartForm does
+	if r.PostForm == nil {
+		r.PostForm = make(url.Values)
+	}
+	for k, vs := range form.Value {
+		r.Form[k] = append(r.Form[k], vs...)
+		r.PostForm[k] = append(r.PostForm[k], vs...)
+	}
+	r.MultipartForm = form
+	return nil
+}
+
+// copyParts copies the parts of mr to mw, failing once a file exceeds maxFileSize bytes.
+func copyParts(mw *multipart.Writer, mr *multipart.Reader, maxFileSize int64) error {
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return mw.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		dst, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+
+		if part.FileName() == "" {
+			if _, err := io.Copy(dst, part); err != nil {
+				return err
+			}
+			continue
+		}
+
+		n, err := io.Copy(dst, io.LimitReader(part, maxFileSize+1))
+		if err != nil {
+			return err
+		}
+		if n > maxFileSize {
+			return &DecodeError{
+				Status: http.StatusRequestEntityTooLarge,
+				Err:    fmt.Errorf("file %s exceeds the maximum size of %d bytes", part.FormName(), maxFileSize),
+			}
+		}
+	}
+}
+
+// DecodeValid decodes the request like Decode and validates the result.
+// The rules of validate struct tags are checked first, then the Valid and ValidMessages
+// methods if T implements Validator or MessageValidator. Problems reported by the methods
+// take precedence for the same key. All problems are translated for the request's language,
+// the untranslated messages are available through ValidationError. Malformed validate tags
+// are returned as an error; check them at startup with validation.Verify.
+func DecodeValid[T any](r *http.Request, opts ...DecodeOption) (T, map[string]string, error) {
+	v, err := Decode[T](r, opts...)
+	if err != nil {
+		return v, nil, fmt.Errorf("decode: %w", err)
+	}
+
+	ctx := r.Context()
+	messages, err := validation.Check(v)
+	if err != nil {
+		return v, nil, fmt.Errorf("validate: %w", err)
+	}
+	if validator, ok := any(v).(Validator); ok {
+		messages.Merge(validation.FromStrings(validator.Valid(ctx)))
+	}
+	if validator, ok := any(v).(MessageValidator); ok {
+		messages.Merge(validator.ValidMessages(ctx))
+	}
+
+	if len(messages) > 0 {
+		problems := messages.Localize(ctx)
+		return v, problems, &ValidationError{Type: fmt.Sprintf("%T", v), Problems: problems, Messages: messages}
 	}
 	return v, nil, nil
 }
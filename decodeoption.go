@@ -0,0 +1,84 @@
+package muxo
+
+import "net/http"
+
+// DefaultMaxMemory is the number of bytes of a multipart form kept in memory, the rest is stored in temporary files.
+const DefaultMaxMemory int64 = 32 << 20
+
+// decodeConfig holds the options of a Decode call.
+type decodeConfig struct {
+	maxMemory             int64
+	maxFileSize           int64
+	maxBodySize           int64
+	w                     http.ResponseWriter
+	disallowUnknownFields bool
+	singleValue           bool
+	strictContentType     bool
+}
+
+// DecodeOption configures a Decode call.
+type DecodeOption func(cfg *decodeConfig)
+
+// WithMaxMemory sets the number of bytes of a multipart form kept in memory.
+func WithMaxMemory(n int64) DecodeOption {
+	return func(cfg *decodeConfig) {
+		cfg.maxMemory = n
+	}
+}
+
+// WithMaxFileSize rejects multipart uploads containing a file larger than n bytes with a 413 DecodeError.
+// The limit is checked while the form is read, before the file is buffered.
+func WithMaxFileSize(n int64) DecodeOption {
+	return func(cfg *decodeConfig) {
+		cfg.maxFileSize = n
+	}
+}
+
+// WithMaxBodySize limits the request body to n bytes. Larger bodies fail with a 413 DecodeError
+// and, like with http.MaxBytesReader, the server closes the connection after responding on w.
+func WithMaxBodySize(w http.ResponseWriter, n int64) DecodeOption {
+	return func(cfg *decodeConfig) {
+		cfg.w = w
+		cfg.maxBodySize = n
+	}
+}
+
+// WithDisallowUnknownFields rejects JSON objects with fields that do not exist in the target type.
+func WithDisallowUnknownFields() DecodeOption {
+	return func(cfg *decodeConfig) {
+		cfg.disallowUnknownFields = true
+	}
+}
+
+// WithSingleValue rejects JSON bodies with anything but whitespace after the first value.
+func WithSingleValue() DecodeOption {
+	return func(cfg *decodeConfig) {
+		cfg.singleValue = true
+	}
+}
+
+// WithStrictContentType requires the media type to match exactly instead of by prefix,
+// and rejects charsets other than UTF-8.
+func WithStrictContentType() DecodeOption {
+	return func(cfg *decodeConfig) {
+		cfg.strictContentType = true
+	}
+}
+
+// WithStrict enables all strict JSON checks: unknown fields, single value and content type.
+func WithStrict() DecodeOption {
+	return func(cfg *decodeConfig) {
+		cfg.disallowUnknownFields = true
+		cfg.singleValue = true
+		cfg.strictContentType = true
+	}
+}
+
+// newDecodeConfig applies opts to the default configuration.
+func newDecodeConfig(opts []DecodeOption) *decodeConfig {
+	cfg := &decodeConfig{maxMemory: DefaultMaxMemory}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
@@ -1,25 +1,62 @@
 package muxo
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"github.com/a-h/templ"
+	"github.com/up1io/muxo/render"
 	"net/http"
 )
 
 func Encode[T any](w http.ResponseWriter, r *http.Request, status int, v T) error {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(v); err != nil {
-		return fmt.Errorf("encode json: %w", err)
-	}
-	return nil
+	return render.JSON(w, status, v)
 }
 
+// EncodeRender renders v with status 200, wrapped in the layout of the request, if any.
+// See EncodeRenderStatus.
 func EncodeRender(w http.ResponseWriter, r *http.Request, v templ.Component) error {
-	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
-	if err := v.Render(r.Context(), w); err != nil {
+	return EncodeRenderStatus(w, r, http.StatusOK, v)
+}
+
+// EncodeRenderStatus renders v with the given status, wrapped in the layout of the request, if any.
+// The page is rendered into a buffer first: v is rendered before the layout, so its components
+// can populate slots, and on a render error nothing has been written yet, so the caller (or
+// HandlerFunc) can still respond with a proper error.
+func EncodeRenderStatus(w http.ResponseWriter, r *http.Request, status int, v templ.Component) error {
+	ctx := newSlotsContext(r.Context())
+
+	var body bytes.Buffer
+	if err := v.Render(ctx, &body); err != nil {
 		return fmt.Errorf("render: %w", err)
 	}
+
+	page := &body
+	if layout, ok := LayoutFromContext(ctx); ok {
+		page = &bytes.Buffer{}
+		if err := layout(templ.Raw(body.String())).Render(ctx, page); err != nil {
+			return fmt.Errorf("render layout: %w", err)
+		}
+	}
+
+	return writeHTML(w, status, page)
+}
+
+// EncodeRenderFragment renders v with the given status without layout, e.g. for partial updates.
+// Like EncodeRenderStatus, the fragment is buffered before anything is written.
+func EncodeRenderFragment(w http.ResponseWriter, r *http.Request, status int, v templ.Component) error {
+	var body bytes.Buffer
+	if err := v.Render(newSlotsContext(r.Context()), &body); err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	return writeHTML(w, status, &body)
+}
+
+// writeHTML writes a buffered HTML response.
+func writeHTML(w http.ResponseWriter, status int, b *bytes.Buffer) error {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.WriteHeader(status)
+	if _, err := b.WriteTo(w); err != nil {
+		return fmt.Errorf("write html: %w", err)
+	}
 	return nil
 }
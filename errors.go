@@ -0,0 +1,126 @@
+package muxo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/middleware"
+	"github.com/up1io/muxo/render"
+	"github.com/up1io/muxo/validation"
+	"net/http"
+)
+
+// HTTPError is an error that carries an HTTP status, a message that is safe to show
+// to clients and an internal cause that is only logged.
+type HTTPError struct {
+	// Status is the HTTP status code of the response
+	Status int
+	// Message is shown to the client, defaults to the status text
+	Message string
+	// Err is the internal cause, it is logged but never sent to the client
+	Err error
+}
+
+// NewHTTPError creates a new HTTPError.
+func NewHTTPError(status int, message string, err error) *HTTPError {
+	return &HTTPError{Status: status, Message: message, Err: err}
+}
+
+// Error implements the error interface.
+func (e *HTTPError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %s", e.Status, msg, e.Err.Error())
+	}
+	return fmt.Sprintf("%d %s", e.Status, msg)
+}
+
+// Unwrap returns the internal cause.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned by DecodeValid when the decoded value has problems.
+type ValidationError struct {
+	// Type is the name of the validated type
+	Type string
+	// Problems maps field names to problem descriptions translated for the request's language
+	Problems map[string]string
+	// Messages holds the untranslated problems with their message ids and parameters
+	Messages validation.Problems
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %d problems", e.Type, len(e.Problems))
+}
+
+// ErrorPage renders the HTML body for errors returned from a HandlerFunc.
+// Replace it to serve a custom templ error page.
+var ErrorPage = render.ErrorPage
+
+// WriteError writes a consistent error response for err.
+// HTTPError values keep their status and message, ValidationError values become 422
+// with the problems map, BindError values become 400 with the conversion problems,
+// DecodeError values keep their status with a generic detail, Problem values are written as they are and
+// any other error becomes a 500 without exposing its details.
+// The response is an application/problem+json document for clients preferring JSON
+// and an HTML page rendered by ErrorPage otherwise.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	log := logger.FromContext(r.Context())
+
+	var problem *Problem
+	var httpErr *HTTPError
+	var validationErr *ValidationError
+	var decodeErr *DecodeError
+	var bindErr *BindError
+	switch {
+	case errors.As(err, &problem):
+		// handlers may return shared problems, per-request members go into a copy
+		problem = problem.Clone()
+	case errors.As(err, &validationErr):
+		problem = NewValidationProblem(validationErr.Problems)
+	case errors.As(err, &bindErr):
+		problem = NewProblem(http.StatusBadRequest, "The request contains invalid parameters.").
+			With("errors", bindErr.Fields)
+	case errors.As(err, &decodeErr):
+		problem = NewProblem(decodeErr.Status, decodeErr.Detail())
+	case errors.As(err, &httpErr):
+		problem = NewProblem(httpErr.Status, httpErr.Message)
+	default:
+		problem = NewProblem(http.StatusInternalServerError, "")
+	}
+
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+
+	status := problem.Status
+	message := problem.Detail
+	if message == "" {
+		message = problem.Title
+	}
+
+	if status >= http.StatusInternalServerError {
+		log.Error("%s %s: %s", r.Method, r.URL.Path, err.Error())
+	} else {
+		log.Debug("%s %s: %s", r.Method, r.URL.Path, err.Error())
+	}
+
+	if render.PrefersJSON(r) {
+		if requestID, ok := middleware.RequestIDFromContext(r.Context()); ok {
+			problem.With("request_id", requestID)
+		}
+		if err := EncodeProblem(w, r, problem); err != nil {
+			log.Error("failed to write error response: %s", err.Error())
+		}
+		return
+	}
+
+	if err := render.HTML(w, r, status, ErrorPage(status, message)); err != nil {
+		log.Error("failed to write error response: %s", err.Error())
+	}
+}
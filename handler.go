@@ -0,0 +1,14 @@
+package muxo
+
+import "net/http"
+
+// HandlerFunc is an HTTP handler that returns an error instead of writing it.
+// Returned errors are turned into responses by WriteError.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls h and writes the returned error, if any.
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
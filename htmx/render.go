@@ -0,0 +1,56 @@
+package htmx
+
+import (
+	"context"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/up1io/muxo"
+	"io"
+	"net/http"
+)
+
+// Render renders fragment without layout for htmx requests and full for every other request,
+// so the same handler serves the partial swap and the initial page load.
+// Vary is set for HX-Request, HX-Boosted and HX-History-Restore-Request, which all decide
+// between the variants, so caches keep them apart.
+func Render(w http.ResponseWriter, r *http.Request, fragment, full templ.Component) error {
+	w.Header().Add("Vary", HeaderRequest+", "+HeaderBoosted+", "+HeaderHistoryRestoreRequest)
+
+	if WantsFragment(r) {
+		return muxo.EncodeRenderFragment(w, r, http.StatusOK, fragment)
+	}
+	return muxo.EncodeRender(w, r, full)
+}
+
+// RenderPage renders c on its own for htmx requests and wrapped in the layout of the
+// request for every other request.
+func RenderPage(w http.ResponseWriter, r *http.Request, c templ.Component) error {
+	return Render(w, r, c, c)
+}
+
+// RenderOOB renders main followed by out-of-band components in one response without layout.
+// Wrap out-of-band components with OOB unless they carry hx-swap-oob themselves.
+func RenderOOB(w http.ResponseWriter, r *http.Request, main templ.Component, oob ...templ.Component) error {
+	return muxo.EncodeRenderFragment(w, r, http.StatusOK, templ.Join(append([]templ.Component{main}, oob...)...))
+}
+
+// OOB wraps c in an element with the given id that htmx swaps out of band,
+// replacing the element with the same id on the page.
+func OOB(id string, c templ.Component) templ.Component {
+	return OOBSwap(id, "true", c)
+}
+
+// OOBSwap is like OOB but with an explicit swap strategy, e.g. "beforeend:#list".
+func OOBSwap(id, strategy string, c templ.Component) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		if _, err := fmt.Fprintf(w, `<div id="%s" hx-swap-oob="%s">`,
+			templ.EscapeString(id), templ.EscapeString(strategy)); err != nil {
+			return err
+		}
+		if err := c.Render(ctx, w); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, `</div>`)
+		return err
+	})
+}
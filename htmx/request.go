@@ -0,0 +1,63 @@
+// Package htmx provides helpers for serving htmx requests with templ components.
+package htmx
+
+import "net/http"
+
+// Request headers sent by htmx.
+const (
+	HeaderRequest               = "HX-Request"
+	HeaderBoosted               = "HX-Boosted"
+	HeaderCurrentURL            = "HX-Current-URL"
+	HeaderHistoryRestoreRequest = "HX-History-Restore-Request"
+	HeaderPrompt                = "HX-Prompt"
+	HeaderTarget                = "HX-Target"
+	HeaderTriggerName           = "HX-Trigger-Name"
+	HeaderTrigger               = "HX-Trigger"
+)
+
+// IsRequest reports whether r was issued by htmx.
+func IsRequest(r *http.Request) bool {
+	return r.Header.Get(HeaderRequest) == "true"
+}
+
+// IsBoosted reports whether r was issued by an element using hx-boost.
+// Boosted requests expect a full page.
+func IsBoosted(r *http.Request) bool {
+	return r.Header.Get(HeaderBoosted) == "true"
+}
+
+// IsHistoryRestoreRequest reports whether r restores a page missing from the history cache.
+// Such requests expect a full page.
+func IsHistoryRestoreRequest(r *http.Request) bool {
+	return r.Header.Get(HeaderHistoryRestoreRequest) == "true"
+}
+
+// WantsFragment reports whether r expects a page fragment instead of the full layout.
+func WantsFragment(r *http.Request) bool {
+	return IsRequest(r) && !IsBoosted(r) && !IsHistoryRestoreRequest(r)
+}
+
+// Target returns the id of the target element, if any.
+func Target(r *http.Request) string {
+	return r.Header.Get(HeaderTarget)
+}
+
+// Trigger returns the id of the triggered element, if any.
+func Trigger(r *http.Request) string {
+	return r.Header.Get(HeaderTrigger)
+}
+
+// TriggerName returns the name of the triggered element, if any.
+func TriggerName(r *http.Request) string {
+	return r.Header.Get(HeaderTriggerName)
+}
+
+// CurrentURL returns the URL of the browser when the request was issued.
+func CurrentURL(r *http.Request) string {
+	return r.Header.Get(HeaderCurrentURL)
+}
+
+// Prompt returns the user response to an hx-prompt.
+func Prompt(r *http.Request) string {
+	return r.Header.Get(HeaderPrompt)
+}
@@ -0,0 +1,88 @@
+package htmx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Response headers understood by htmx.
+const (
+	HeaderLocation           = "HX-Location"
+	HeaderPushURL            = "HX-Push-Url"
+	HeaderRedirect           = "HX-Redirect"
+	HeaderRefresh            = "HX-Refresh"
+	HeaderReplaceURL         = "HX-Replace-Url"
+	HeaderReswap             = "HX-Reswap"
+	HeaderRetarget           = "HX-Retarget"
+	HeaderReselect           = "HX-Reselect"
+	HeaderTriggerAfterSettle = "HX-Trigger-After-Settle"
+	HeaderTriggerAfterSwap   = "HX-Trigger-After-Swap"
+)
+
+// Redirect makes htmx perform a full page redirect to url.
+func Redirect(w http.ResponseWriter, url string) {
+	w.Header().Set(HeaderRedirect, url)
+}
+
+// Location makes htmx load url with an ajax request, without a full page reload.
+func Location(w http.ResponseWriter, url string) {
+	w.Header().Set(HeaderLocation, url)
+}
+
+// Refresh makes htmx reload the full page.
+func Refresh(w http.ResponseWriter) {
+	w.Header().Set(HeaderRefresh, "true")
+}
+
+// PushURL pushes url into the browser history.
+func PushURL(w http.ResponseWriter, url string) {
+	w.Header().Set(HeaderPushURL, url)
+}
+
+// ReplaceURL replaces the current URL in the browser location bar.
+func ReplaceURL(w http.ResponseWriter, url string) {
+	w.Header().Set(HeaderReplaceURL, url)
+}
+
+// Retarget replaces the target of the swap with the element matching selector.
+func Retarget(w http.ResponseWriter, selector string) {
+	w.Header().Set(HeaderRetarget, selector)
+}
+
+// Reswap overrides the swap strategy, e.g. "outerHTML" or "beforeend".
+func Reswap(w http.ResponseWriter, strategy string) {
+	w.Header().Set(HeaderReswap, strategy)
+}
+
+// Reselect selects the part of the response used for the swap.
+func Reselect(w http.ResponseWriter, selector string) {
+	w.Header().Set(HeaderReselect, selector)
+}
+
+// TriggerEvents triggers client side events as soon as the response is received.
+func TriggerEvents(w http.ResponseWriter, events ...string) {
+	w.Header().Set(HeaderTrigger, strings.Join(events, ", "))
+}
+
+// TriggerEventsAfterSwap triggers client side events after the swap step.
+func TriggerEventsAfterSwap(w http.ResponseWriter, events ...string) {
+	w.Header().Set(HeaderTriggerAfterSwap, strings.Join(events, ", "))
+}
+
+// TriggerEventsAfterSettle triggers client side events after the settle step.
+func TriggerEventsAfterSettle(w http.ResponseWriter, events ...string) {
+	w.Header().Set(HeaderTriggerAfterSettle, strings.Join(events, ", "))
+}
+
+// TriggerDetail triggers client side events with details, encoded as a JSON object
+// mapping event names to their detail values.
+func TriggerDetail(w http.ResponseWriter, events map[string]any) error {
+	b, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("encode htmx trigger: %w", err)
+	}
+	w.Header().Set(HeaderTrigger, string(b))
+	return nil
+}
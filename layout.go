@@ -0,0 +1,113 @@
+package muxo
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"github.com/up1io/muxo/middleware"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Layout wraps the body of a page into the document shell.
+type Layout func(body templ.Component) templ.Component
+
+type layoutKey struct{}
+
+// NewLayoutContext returns a new Context that carries the layout l. A nil layout disables layouts.
+func NewLayoutContext(ctx context.Context, l Layout) context.Context {
+	return context.WithValue(ctx, layoutKey{}, l)
+}
+
+// LayoutFromContext returns the layout stored in ctx, if any.
+func LayoutFromContext(ctx context.Context) (Layout, bool) {
+	l, ok := ctx.Value(layoutKey{}).(Layout)
+	return l, ok && l != nil
+}
+
+// WithLayout sets the default layout applied by EncodeRender for all routes.
+func WithLayout(l Layout) AppOption {
+	return func(app *App) {
+		app.layout = l
+	}
+}
+
+// UseLayout creates a middleware that overrides the layout for the wrapped routes.
+// Pass nil to render the routes without layout.
+func UseLayout(l Layout) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(NewLayoutContext(r.Context(), l)))
+		})
+	}
+}
+
+// slots holds the named components populated while rendering a page.
+type slots struct {
+	mu sync.Mutex
+	m  map[string][]templ.Component
+}
+
+type slotsKey struct{}
+
+// newSlotsContext returns a new Context with empty slots.
+func newSlotsContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, slotsKey{}, &slots{m: make(map[string][]templ.Component)})
+}
+
+// slotsFromContext returns the slots of the page being rendered, if any.
+func slotsFromContext(ctx context.Context) (*slots, bool) {
+	s, ok := ctx.Value(slotsKey{}).(*slots)
+	return s, ok
+}
+
+// SetSlot replaces the content of the named slot. Components of the page body call it
+// while rendering, the layout renders the slot with Slot.
+func SetSlot(ctx context.Context, name string, c templ.Component) {
+	if s, ok := slotsFromContext(ctx); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.m[name] = []templ.Component{c}
+	}
+}
+
+// AppendSlot adds c to the named slot, e.g. to collect the scripts of several components.
+func AppendSlot(ctx context.Context, name string, c templ.Component) {
+	if s, ok := slotsFromContext(ctx); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.m[name] = append(s.m[name], c)
+	}
+}
+
+// SetTitle sets the "title" slot to the escaped text title.
+func SetTitle(ctx context.Context, title string) {
+	SetSlot(ctx, "title", templ.Raw(templ.EscapeString(title)))
+}
+
+// Slot renders the content of the named slot.
+func Slot(name string) templ.Component {
+	return SlotOr(name, templ.NopComponent)
+}
+
+// SlotOr renders the content of the named slot, or fallback if the slot is empty.
+func SlotOr(name string, fallback templ.Component) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		var content []templ.Component
+		if s, ok := slotsFromContext(ctx); ok {
+			s.mu.Lock()
+			content = s.m[name]
+			s.mu.Unlock()
+		}
+
+		if len(content) == 0 {
+			return fallback.Render(ctx, w)
+		}
+		for _, c := range content {
+			if err := c.Render(ctx, w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrWriterClosed is returned when writing to an AsyncWriter after Close.
+var ErrWriterClosed = errors.New("logger: writer closed")
+
+// asyncEntry is a single queued log line.
+type asyncEntry struct {
+	level Level
+	// leveled is set for lines queued with WriteLevel, plain writes carry no level
+	leveled bool
+	line    []byte
+	// done is set for flush markers instead of a log line
+	done chan struct{}
+}
+
+// AsyncWriter buffers log lines in memory and writes them to the underlying writer
+// on a background goroutine, so a slow destination never blocks the caller.
+// When the buffer is full new lines are dropped and counted.
+type AsyncWriter struct {
+	out     io.Writer
+	queue   chan asyncEntry
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// NewAsyncWriter creates an AsyncWriter that buffers up to size lines before dropping.
+func NewAsyncWriter(out io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = 1024
+	}
+
+	w := &AsyncWriter{
+		out:   out,
+		queue: make(chan asyncEntry, size),
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w
+}
+
+// run drains the queue until it is closed.
+func (w *AsyncWriter) run() {
+	defer w.wg.Done()
+
+	for e := range w.queue {
+		if e.done != nil {
+			close(e.done)
+			continue
+		}
+
+		if lw, ok := w.out.(LevelWriter); ok && e.leveled {
+			_, _ = lw.WriteLevel(e.level, e.line)
+		} else {
+			_, _ = w.out.Write(e.line)
+		}
+	}
+}
+
+// Write queues p without a level, it is passed on with Write as well. It never blocks.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	return w.enqueue(asyncEntry{}, p)
+}
+
+// WriteLevel queues p together with its level. It never blocks.
+func (w *AsyncWriter) WriteLevel(level Level, p []byte) (int, error) {
+	return w.enqueue(asyncEntry{level: level, leveled: true}, p)
+}
+
+// enqueue queues e with a copy of p, or drops it if the buffer is full.
+func (w *AsyncWriter) enqueue(e asyncEntry, p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	e.line = make([]byte, len(p))
+	copy(e.line, p)
+
+	select {
+	case w.queue <- e:
+	default:
+		w.dropped.Add(1)
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of lines dropped because the buffer was full.
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Flush blocks until every line queued before the call has been written.
+func (w *AsyncWriter) Flush() {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return
+	}
+	done := make(chan struct{})
+	w.queue <- asyncEntry{done: done}
+	w.mu.RUnlock()
+
+	<-done
+}
+
+// Close writes all pending lines and closes the underlying writer if it implements io.Closer.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+
+	if c, ok := w.out.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"context"
+	"strings"
+)
+
+type key int
+
+var loggerKey key
+
+// NewContext returns a new Context that carries the logger l.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the Logger stored in ctx, or Default if there is none.
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey).(Logger); ok {
+		return l
+	}
+	return Default
+}
+
+// fieldLogger prefixes every message with a fixed key=value pair.
+type fieldLogger struct {
+	Logger
+	prefix string
+}
+
+// With returns a Logger that prefixes every message of l with key=value.
+func With(l Logger, key, value string) Logger {
+	// escape the prefix, it becomes part of the format string
+	prefix := strings.ReplaceAll(key+"="+value+" ", "%", "%%")
+
+	return &fieldLogger{Logger: l, prefix: prefix}
+}
+
+// Debug logs a message at DEBUG level
+func (l *fieldLogger) Debug(format string, args ...interface{}) {
+	l.Logger.Debug(l.prefix+format, args...)
+}
+
+// Info logs a message at INFO level
+func (l *fieldLogger) Info(format string, args ...interface{}) {
+	l.Logger.Info(l.prefix+format, args...)
+}
+
+// Warn logs a message at WARN level
+func (l *fieldLogger) Warn(format string, args ...interface{}) {
+	l.Logger.Warn(l.prefix+format, args...)
+}
+
+// Error logs a message at ERROR level
+func (l *fieldLogger) Error(format string, args ...interface{}) {
+	l.Logger.Error(l.prefix+format, args...)
+}
@@ -73,18 +73,26 @@ func (l *DefaultLogger) GetLevel() Level {
 
 // log logs a message at the specified level
 func (l *DefaultLogger) log(level Level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if level < l.level {
 		return
 	}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
 	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
 	levelName := levelNames[level]
 	message := fmt.Sprintf(format, args...)
+	line := []byte(fmt.Sprintf("[%s] [%s] %s\n", timestamp, levelName, message))
+
+	// Writers that care about the level of a line (e.g. a MultiWriter with
+	// per-sink minimum levels) receive it alongside the formatted output.
+	if lw, ok := l.out.(LevelWriter); ok {
+		_, _ = lw.WriteLevel(level, line)
+		return
+	}
 
-	fmt.Fprintf(l.out, "[%s] [%s] %s\n", timestamp, levelName, message)
+	_, _ = l.out.Write(line)
 }
 
 // Debug logs a message at DEBUG level
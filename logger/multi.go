@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"errors"
+	"io"
+)
+
+// LevelWriter is an io.Writer that also accepts the level of the line being written.
+// DefaultLogger uses WriteLevel instead of Write when its output implements it.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level Level, p []byte) (n int, err error)
+}
+
+// Sink is a log destination with its own minimum level.
+type Sink struct {
+	// Writer receives every line at or above Level
+	Writer io.Writer
+	// Level is the minimum level written to Writer
+	Level Level
+}
+
+// MultiWriter fans out log lines to several sinks.
+type MultiWriter struct {
+	sinks []Sink
+}
+
+// NewMultiWriter creates a MultiWriter that writes to the given sinks.
+func NewMultiWriter(sinks ...Sink) *MultiWriter {
+	return &MultiWriter{sinks: sinks}
+}
+
+// Write writes p to every sink regardless of its level.
+func (m *MultiWriter) Write(p []byte) (int, error) {
+	var errs []error
+	for _, s := range m.sinks {
+		if _, err := s.Writer.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return len(p), errors.Join(errs...)
+}
+
+// WriteLevel writes p to every sink whose minimum level is at or below level.
+func (m *MultiWriter) WriteLevel(level Level, p []byte) (int, error) {
+	var errs []error
+	for _, s := range m.sinks {
+		if level < s.Level {
+			continue
+		}
+
+		var err error
+		if lw, ok := s.Writer.(LevelWriter); ok {
+			_, err = lw.WriteLevel(level, p)
+		} else {
+			_, err = s.Writer.Write(p)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return len(p), errors.Join(errs...)
+}
+
+// Close closes every sink that implements io.Closer.
+func (m *MultiWriter) Close() error {
+	var errs []error
+	for _, s := range m.sinks {
+		if c, ok := s.Writer.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewMultiLogger creates a DefaultLogger that fans out to the given sinks.
+// The logger level is set to the lowest sink level so no sink misses a line.
+func NewMultiLogger(sinks ...Sink) *DefaultLogger {
+	level := ERROR
+	for _, s := range sinks {
+		if s.Level < level {
+			level = s.Level
+		}
+	}
+
+	return NewLogger(NewMultiWriter(sinks...), level)
+}
@@ -0,0 +1,269 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp appended to rotated file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is an io.WriteCloser that writes to a file and rotates it
+// once it reaches MaxSize bytes or Interval has elapsed.
+// Rotated files are renamed to <name>-<timestamp><ext> and optionally gzipped.
+type RotatingFile struct {
+	// Filename is the file to write to
+	Filename string
+	// MaxSize is the size in bytes after which the file is rotated, zero disables size-based rotation
+	MaxSize int64
+	// Interval is the duration after which the file is rotated, zero disables time-based rotation
+	Interval time.Duration
+	// MaxBackups is the number of rotated files to keep, zero keeps all of them
+	MaxBackups int
+	// MaxAge is the duration rotated files are kept, zero keeps them forever
+	MaxAge time.Duration
+	// Compress gzips rotated files
+	Compress bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	wg       sync.WaitGroup
+}
+
+// NewRotatingFile creates a RotatingFile writing to filename.
+// The file is opened lazily on the first write.
+func NewRotatingFile(filename string, maxSize int64, maxBackups int) *RotatingFile {
+	return &RotatingFile{
+		Filename:   filename,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+}
+
+// Write writes p to the current file, rotating it first if needed.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file and starts a new one.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.rotate()
+}
+
+// Close closes the current file and waits for pending compressions.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := f.close()
+	f.wg.Wait()
+	return err
+}
+
+// shouldRotate reports whether writing n more bytes requires a rotation.
+func (f *RotatingFile) shouldRotate(n int64) bool {
+	if f.MaxSize > 0 && f.size > 0 && f.size+n > f.MaxSize {
+		return true
+	}
+	if f.Interval > 0 && time.Since(f.openedAt) >= f.Interval {
+		return true
+	}
+	return false
+}
+
+// open opens the log file for appending, creating it if necessary.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Filename), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+// close closes the current file if one is open.
+func (f *RotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// rotate renames the current file to a backup name and opens a fresh file.
+func (f *RotatingFile) rotate() error {
+	if err := f.close(); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(f.Filename)
+	prefix := strings.TrimSuffix(f.Filename, ext)
+	backup := backupName(prefix, ext, time.Now())
+
+	if err := os.Rename(f.Filename, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+
+		if f.Compress {
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "logger: failed to compress %s: %s\n", backup, err)
+			}
+		}
+		if err := f.cleanup(prefix, ext); err != nil {
+			fmt.Fprintf(os.Stderr, "logger: failed to remove old log files: %s\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// backupName returns an unused name for a file rotated at t. Rotations within the same
+// millisecond get a counter, e.g. app-<timestamp>-1.log, so no backup is overwritten.
+func backupName(prefix, ext string, t time.Time) string {
+	stamp := t.Format(backupTimeFormat)
+	name := fmt.Sprintf("%s-%s%s", prefix, stamp, ext)
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s-%s-%d%s", prefix, stamp, i, ext)
+	}
+	return name
+}
+
+// exists reports whether a file exists at path.
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// cleanup removes rotated files exceeding MaxBackups or older than MaxAge.
+func (f *RotatingFile) cleanup(prefix, ext string) error {
+	if f.MaxBackups <= 0 && f.MaxAge <= 0 {
+		return nil
+	}
+
+	matches, err := filepath.Glob(prefix + "-*" + ext + "*")
+	if err != nil {
+		return err
+	}
+
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+
+	var backups []backup
+	for _, m := range matches {
+		// skip files that merely share the prefix, e.g. app-error.log next to app.log
+		stamp := strings.TrimPrefix(m, prefix+"-")
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp[:len(backupTimeFormat)]); err != nil {
+			continue
+		}
+
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: m, modTime: info.ModTime()})
+	}
+
+	// newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+
+	var errs []error
+	for i, b := range backups {
+		expired := f.MaxAge > 0 && time.Since(b.modTime) > f.MaxAge
+		excess := f.MaxBackups > 0 && i >= f.MaxBackups
+		if expired || excess {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// compressFile gzips path into path.gz and removes the original.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Named is a middleware with a name, so it can be found in a Chain.
+type Named struct {
+	Name       string
+	Middleware Middleware
+	// Origin describes how the entry got into the chain, e.g. "appended"
+	Origin string
+	// derived is set for names taken from the constructor, which need not be unique
+	derived bool
+}
+
+// Name names mw. Names given this way must be unique within a Chain.
+func Name(name string, mw Middleware) Named {
+	return Named{Name: name, Middleware: mw}
+}
+
+// Unnamed names mw after the function that created it, e.g. "middleware.Recover".
+// Several entries may share such a name, they can only be addressed while it is unique.
+func Unnamed(mw Middleware) Named {
+	return Named{Name: funcName(mw), Middleware: mw, derived: true}
+}
+
+// Chain is an ordered middleware stack whose entries can be addressed by name.
+// The first entry is the outermost middleware, as with CreateStack.
+type Chain struct {
+	items []Named
+}
+
+// NewChain creates a Chain of items. It returns an error if two items share a name
+// given with Name.
+func NewChain(items ...Named) (*Chain, error) {
+	c := &Chain{}
+	if err := c.Append(items...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Append adds items to the end of the chain.
+func (c *Chain) Append(items ...Named) error {
+	return c.insert(len(c.items), items)
+}
+
+// InsertBefore adds items in front of the middleware called name, so they run before it.
+func (c *Chain) InsertBefore(name string, items ...Named) error {
+	i, err := c.index(name)
+	if err != nil {
+		return err
+	}
+	return c.insert(i, items)
+}
+
+// InsertAfter adds items behind the middleware called name, so they run after it.
+func (c *Chain) InsertAfter(name string, items ...Named) error {
+	i, err := c.index(name)
+	if err != nil {
+		return err
+	}
+	return c.insert(i+1, items)
+}
+
+// Replace replaces the middleware called name with mw, keeping its position and name.
+func (c *Chain) Replace(name string, mw Middleware) error {
+	i, err := c.index(name)
+	if err != nil {
+		return err
+	}
+	c.items[i].Middleware = mw
+	return nil
+}
+
+// Remove removes the middleware called name.
+func (c *Chain) Remove(name string) error {
+	i, err := c.index(name)
+	if err != nil {
+		return err
+	}
+	c.items = slices.Delete(c.items, i, i+1)
+	return nil
+}
+
+// Items returns the entries of the chain in order.
+func (c *Chain) Items() []Named {
+	return slices.Clone(c.items)
+}
+
+// Names returns the names of the middleware in order.
+func (c *Chain) Names() []string {
+	names := make([]string, len(c.items))
+	for i, item := range c.items {
+		names[i] = item.Name
+	}
+	return names
+}
+
+// Middleware composes the chain into a single Middleware.
+func (c *Chain) Middleware() Middleware {
+	xs := make([]Middleware, len(c.items))
+	for i, item := range c.items {
+		xs[i] = item.Middleware
+	}
+	return CreateStack(xs...)
+}
+
+// insert adds items at position i, rejecting names given with Name that are taken.
+func (c *Chain) insert(i int, items []Named) error {
+	for j, item := range items {
+		if item.derived {
+			continue
+		}
+		taken := slices.ContainsFunc(c.items, func(other Named) bool { return other.Name == item.Name }) ||
+			slices.ContainsFunc(items[:j], func(other Named) bool { return other.Name == item.Name })
+		if taken {
+			return fmt.Errorf("middleware: duplicate middleware name %q", item.Name)
+		}
+	}
+
+	c.items = slices.Insert(c.items, i, items...)
+	return nil
+}
+
+// index returns the position of the middleware called name. Names given with Name take
+// precedence, derived names must be unique to be addressed.
+func (c *Chain) index(name string) (int, error) {
+	found := -1
+	for i, item := range c.items {
+		if item.Name != name {
+			continue
+		}
+		if !item.derived {
+			return i, nil
+		}
+		if found >= 0 {
+			return -1, fmt.Errorf("middleware: several middleware are named %q, name them with Name", name)
+		}
+		found = i
+	}
+
+	if found < 0 {
+		return -1, fmt.Errorf("middleware: no middleware named %q", name)
+	}
+	return found, nil
+}
+
+// funcName returns the package qualified name of the function that created mw, without
+// closure suffixes, e.g. "middleware.Recover" for "middleware.Recover.func1.1".
+func funcName(mw Middleware) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mw).Pointer())
+	if fn == nil {
+		return "middleware"
+	}
+
+	name := fn.Name()
+	// "github.com/up1io/muxo/middleware.Recover.func1" -> "middleware.Recover.func1"
+	name = name[strings.LastIndex(name, "/")+1:]
+	for {
+		i := strings.LastIndex(name, ".")
+		if i < 0 || !isClosureSuffix(name[i+1:]) {
+			return name
+		}
+		name = name[:i]
+	}
+}
+
+// isClosureSuffix reports whether s is a segment the compiler appends to closure names,
+// "func1" for closures and "1" for closures nested in them.
+func isClosureSuffix(s string) bool {
+	s = strings.TrimPrefix(s, "func")
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tracer returns a middleware appending name to the X-Trace header.
+func tracer(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// named returns a Named tracer called name.
+func named(name string) Named {
+	return Name(name, tracer(name))
+}
+
+func TestChainOperations(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      func(c *Chain) error
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "append",
+			op:   func(c *Chain) error { return c.Append(named("d")) },
+			want: []string{"a", "b", "c", "d"},
+		},
+		{
+			name: "insert before first",
+			op:   func(c *Chain) error { return c.InsertBefore("a", named("x"), named("y")) },
+			want: []string{"x", "y", "a", "b", "c"},
+		},
+		{
+			name: "insert before middle",
+			op:   func(c *Chain) error { return c.InsertBefore("b", named("x")) },
+			want: []string{"a", "x", "b", "c"},
+		},
+		{
+			name: "insert after middle",
+			op:   func(c *Chain) error { return c.InsertAfter("b", named("x")) },
+			want: []string{"a", "b", "x", "c"},
+		},
+		{
+			name: "insert after last",
+			op:   func(c *Chain) error { return c.InsertAfter("c", named("x")) },
+			want: []string{"a", "b", "c", "x"},
+		},
+		{
+			name: "remove",
+			op:   func(c *Chain) error { return c.Remove("b") },
+			want: []string{"a", "c"},
+		},
+		{
+			name: "replace keeps position",
+			op:   func(c *Chain) error { return c.Replace("b", tracer("b2")) },
+			want: []string{"a", "b2", "c"},
+		},
+		{
+			name:    "insert before unknown",
+			op:      func(c *Chain) error { return c.InsertBefore("missing", named("x")) },
+			want:    []string{"a", "b", "c"},
+			wantErr: "no middleware named",
+		},
+		{
+			name:    "insert after unknown",
+			op:      func(c *Chain) error { return c.InsertAfter("missing", named("x")) },
+			want:    []string{"a", "b", "c"},
+			wantErr: "no middleware named",
+		},
+		{
+			name:    "remove unknown",
+			op:      func(c *Chain) error { return c.Remove("missing") },
+			want:    []string{"a", "b", "c"},
+			wantErr: "no middleware named",
+		},
+		{
+			name:    "duplicate name",
+			op:      func(c *Chain) error { return c.InsertAfter("a", named("c")) },
+			want:    []string{"a", "b", "c"},
+			wantErr: "duplicate middleware name",
+		},
+		{
+			name:    "duplicate within items",
+			op:      func(c *Chain) error { return c.Append(named("x"), named("x")) },
+			want:    []string{"a", "b", "c"},
+			wantErr: "duplicate middleware name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewChain(named("a"), named("b"), named("c"))
+			if err != nil {
+				t.Fatalf("NewChain() error = %v", err)
+			}
+
+			err = tt.op(c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			// the composed stack runs the middleware in chain order
+			w := httptest.NewRecorder()
+			c.Middleware()(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got := w.Header().Values("X-Trace"); !slices.Equal(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChainDerivedNames(t *testing.T) {
+	c, err := NewChain(Unnamed(tracer("1")), Unnamed(RequestID()), Unnamed(RequestID()))
+	if err != nil {
+		t.Fatalf("NewChain() error = %v", err)
+	}
+
+	want := []string{"middleware.tracer", "middleware.RequestID", "middleware.RequestID"}
+	if got := c.Names(); !slices.Equal(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	if err := c.InsertBefore("middleware.tracer", named("x")); err != nil {
+		t.Errorf("InsertBefore(unique derived name) error = %v", err)
+	}
+	if err := c.Remove("middleware.RequestID"); err == nil {
+		t.Error("Remove(ambiguous derived name) succeeded")
+	}
+}
+
+func TestFuncName(t *testing.T) {
+	nested := func() Middleware {
+		return func(next http.Handler) http.Handler { return next }
+	}()
+
+	tests := []struct {
+		mw   Middleware
+		want string
+	}{
+		{mw: RequestID(), want: "middleware.RequestID"},
+		{mw: Recover(RecoverConfig{}), want: "middleware.Recover"},
+		{mw: tracer("x"), want: "middleware.tracer"},
+		{mw: nested, want: "middleware.TestFuncName"},
+		{mw: marker, want: "middleware.marker"},
+	}
+
+	for _, tt := range tests {
+		if got := funcName(tt.mw); got != tt.want {
+			t.Errorf("funcName() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// When applies mw to requests for which predicate returns true, other requests skip it.
+func When(predicate func(r *http.Request) bool, mw Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if predicate(r) {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ForPaths applies mw to requests whose path matches one of patterns.
+// Patterns use path.Match syntax, where "*" does not match "/". A pattern ending in
+// "/**" matches everything below its prefix, e.g. "/admin/**" matches "/admin" and
+// "/admin/users/1".
+func ForPaths(mw Middleware, patterns ...string) Middleware {
+	return When(func(r *http.Request) bool {
+		return matchPath(r.URL.Path, patterns)
+	}, mw)
+}
+
+// ExceptPaths applies mw to requests whose path matches none of patterns, see ForPaths
+// for the pattern syntax.
+func ExceptPaths(mw Middleware, patterns ...string) Middleware {
+	return When(func(r *http.Request) bool {
+		return !matchPath(r.URL.Path, patterns)
+	}, mw)
+}
+
+// ForMethods applies mw to requests with one of methods, e.g. to protect only unsafe
+// methods.
+func ForMethods(mw Middleware, methods ...string) Middleware {
+	methods = slices.Clone(methods)
+	for i, m := range methods {
+		methods[i] = strings.ToUpper(m)
+	}
+
+	return When(func(r *http.Request) bool {
+		return slices.Contains(methods, r.Method)
+	}, mw)
+}
+
+// matchPath reports whether p matches one of patterns.
+func matchPath(p string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		// malformed patterns never match
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		patterns []string
+		want     bool
+	}{
+		{path: "/admin", patterns: []string{"/admin"}, want: true},
+		{path: "/admin/", patterns: []string{"/admin"}, want: false},
+		{path: "/api/users", patterns: []string{"/api/*"}, want: true},
+		{path: "/api/users/1", patterns: []string{"/api/*"}, want: false},
+		{path: "/api", patterns: []string{"/api/*"}, want: false},
+		{path: "/files/a.css", patterns: []string{"/files/*.css"}, want: true},
+		{path: "/files/a.js", patterns: []string{"/files/*.css"}, want: false},
+		{path: "/v1/users", patterns: []string{"/v?/users"}, want: true},
+
+		// "/**" matches the prefix itself and everything below it
+		{path: "/admin", patterns: []string{"/admin/**"}, want: true},
+		{path: "/admin/", patterns: []string{"/admin/**"}, want: true},
+		{path: "/admin/users/1", patterns: []string{"/admin/**"}, want: true},
+		{path: "/administrator", patterns: []string{"/admin/**"}, want: false},
+		{path: "/adm", patterns: []string{"/admin/**"}, want: false},
+		{path: "/", patterns: []string{"/**"}, want: true},
+		{path: "/anything/below", patterns: []string{"/**"}, want: true},
+
+		{path: "/b", patterns: []string{"/a", "/b"}, want: true},
+		{path: "/c", patterns: []string{"/a", "/b"}, want: false},
+		{path: "/a", patterns: nil, want: false},
+		// malformed patterns never match
+		{path: "/[", patterns: []string{"/["}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPath(tt.path, tt.patterns); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.path, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+// marker is a middleware setting the X-Applied header.
+func marker(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Applied", "1")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestCombinators(t *testing.T) {
+	tests := []struct {
+		name   string
+		mw     Middleware
+		method string
+		path   string
+		want   bool
+	}{
+		{name: "ForPaths match", mw: ForPaths(marker, "/admin/**"), method: http.MethodGet, path: "/admin/users", want: true},
+		{name: "ForPaths miss", mw: ForPaths(marker, "/admin/**"), method: http.MethodGet, path: "/users", want: false},
+		{name: "ExceptPaths match", mw: ExceptPaths(marker, "/health"), method: http.MethodGet, path: "/health", want: false},
+		{name: "ExceptPaths miss", mw: ExceptPaths(marker, "/health"), method: http.MethodGet, path: "/users", want: true},
+		{name: "ForMethods match", mw: ForMethods(marker, "post"), method: http.MethodPost, path: "/", want: true},
+		{name: "ForMethods miss", mw: ForMethods(marker, "post"), method: http.MethodGet, path: "/", want: false},
+		{
+			name:   "When",
+			mw:     When(func(r *http.Request) bool { return r.Header.Get("HX-Request") == "" }, marker),
+			method: http.MethodGet,
+			path:   "/",
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.mw(http.NotFoundHandler()).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if got := w.Header().Get("X-Applied") != ""; got != tt.want {
+				t.Errorf("applied = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
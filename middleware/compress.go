@@ -0,0 +1,335 @@
+package middleware
+
+import (
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinSize is the smallest response body compressed by default.
+// Smaller bodies barely shrink and cost more to compress than they save.
+const DefaultCompressMinSize = 1024
+
+// DefaultCompressibleTypes lists the media types compressed by default. Types ending in
+// "/" match any subtype, "+json" and "+xml" suffixes are compressed as well.
+var DefaultCompressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/x-ndjson",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// CompressConfig configures the Compress middleware.
+type CompressConfig struct {
+	// Encodings lists the supported encodings in order of preference for clients accepting
+	// several equally. Defaults to br, zstd and gzip.
+	Encodings []string
+	// MinSize is the smallest body compressed, defaults to DefaultCompressMinSize
+	MinSize int
+	// ContentTypes lists the compressed media types, defaults to DefaultCompressibleTypes.
+	// Everything else, e.g. images and archives, is already compressed and sent as is.
+	ContentTypes []string
+}
+
+// encoder is a compressing writer that can be reused for another response.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoderPools keeps reusable encoders per encoding, since creating them is expensive.
+var encoderPools = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+	"gzip": {New: func() any {
+		enc, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return enc
+	}},
+}
+
+// Compress creates a middleware that compresses responses with the best encoding accepted
+// by the client. Bodies smaller than MinSize, types not listed in ContentTypes and responses
+// that already have a Content-Encoding are sent as they are. Flushes are passed through the
+// encoder, so streamed pages and event streams reach the client immediately.
+func Compress(cfg CompressConfig) Middleware {
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = []string{"br", "zstd", "gzip"}
+	}
+	for _, e := range cfg.Encodings {
+		if _, ok := encoderPools[e]; !ok {
+			panic("middleware: unsupported encoding " + e)
+		}
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = DefaultCompressMinSize
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressibleTypes
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, cfg: &cfg, encoding: encoding, status: http.StatusOK}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// compressWriter buffers the start of the body until it knows whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg      *CompressConfig
+	encoding string
+
+	status      int
+	wroteHeader bool
+	buf         []byte
+	// decided is set once the response is either compressed or passed through
+	decided bool
+	enc     encoder
+}
+
+// WriteHeader records the status. The header is written once the body decides whether
+// the response is compressed.
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		// informational responses like 103 Early Hints are sent right away
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+	w.wroteHeader = true
+	if !bodyAllowed(status) {
+		_ = w.passthrough(false)
+	}
+}
+
+// Write buffers b until MinSize is reached, then compresses or forwards the body.
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if !w.compressible() {
+		if err := w.passthrough(false); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends the compressed data written so far. A flush before MinSize is reached
+// signals a streamed response, which is compressed if its type is compressible.
+func (w *compressWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is the Flush used by http.ResponseController, it reports errors.
+func (w *compressWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.decided {
+		if w.compressible() {
+			if err := w.startCompression(); err != nil {
+				return err
+			}
+		} else if err := w.passthrough(false); err != nil {
+			return err
+		}
+	}
+
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response may be compressed, based on its header and,
+// if no Content-Type is set, the sniffed type of the buffered body.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" ||
+		strings.Contains(h.Get("Cache-Control"), "no-transform") || w.status == http.StatusPartialContent {
+		return false
+	}
+
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		// the server sniffs the same type when the header is written
+		ct = http.DetectContentType(w.buf)
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	return slices.ContainsFunc(w.cfg.ContentTypes, func(t string) bool {
+		if strings.HasSuffix(t, "/") {
+			return strings.HasPrefix(mediaType, t)
+		}
+		return mediaType == t
+	})
+}
+
+// startCompression writes the header for the compressed response and the buffered body.
+func (w *compressWriter) startCompression() error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	// the compressed body differs from the identity body, so a strong ETag must not match both
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.enc = encoderPools[w.encoding].Get().(encoder)
+	w.enc.Reset(w.ResponseWriter)
+
+	buf := w.buf
+	w.buf = nil
+	_, err := w.enc.Write(buf)
+	return err
+}
+
+// passthrough writes the header and the buffered body without compression.
+// complete reports whether the buffer holds the whole body.
+func (w *compressWriter) passthrough(complete bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+
+	if complete && w.Header().Get("Content-Length") == "" && w.Header().Get("Content-Encoding") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) > 0 {
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+	return nil
+}
+
+// close finishes the response after the handler returned.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if !w.wroteHeader && len(w.buf) == 0 {
+			// nothing was written, let the server write its default response
+			return
+		}
+		_ = w.passthrough(true)
+	}
+
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(nil)
+		encoderPools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// bodyAllowed reports whether a response with status may have a body.
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// negotiateEncoding returns the supported encoding with the highest q-value in the
+// Accept-Encoding header, preferring earlier supported encodings on ties.
+// It returns "" if the client accepts none of them.
+func negotiateEncoding(accept string, supported []string) string {
+	if accept == "" {
+		return ""
+	}
+
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		if name != "" {
+			qs[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		q, ok := qs[enc]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the CORS middleware.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to make cross-origin requests. Entries are
+	// exact origins like "https://example.com", wildcard subdomains like "https://*.example.com"
+	// or "*" for any origin. "*" can't be combined with AllowCredentials.
+	AllowedOrigins []string
+	// AllowOrigin is called for origins not matched by AllowedOrigins
+	AllowOrigin func(r *http.Request, origin string) bool
+	// AllowedMethods defaults to GET, HEAD and POST
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers allowed in preflight requests, "*" allows any
+	// header. Defaults to Accept, Accept-Language, Content-Language and Content-Type.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers readable by the client
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight results, zero omits the header
+	MaxAge time.Duration
+	// PassthroughPreflight hands preflight requests to the next handler instead of
+	// answering them with 204
+	PassthroughPreflight bool
+}
+
+// CORS creates a middleware that implements cross-origin resource sharing. Preflight
+// requests are answered directly. Wrap the route groups that serve other origins, or
+// add it to the App middleware to allow them everywhere.
+func CORS(cfg CORSConfig) Middleware {
+	if cfg.AllowCredentials && slices.Contains(cfg.AllowedOrigins, "*") {
+		panic("middleware: CORS can't allow credentials for any origin")
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = []string{"Accept", "Accept-Language", "Content-Language", "Content-Type"}
+	}
+
+	// normalize copies, the slices of the caller stay untouched
+	cfg.AllowedMethods = slices.Clone(cfg.AllowedMethods)
+	cfg.AllowedHeaders = slices.Clone(cfg.AllowedHeaders)
+	for i, m := range cfg.AllowedMethods {
+		cfg.AllowedMethods[i] = strings.ToUpper(m)
+	}
+	for i, h := range cfg.AllowedHeaders {
+		cfg.AllowedHeaders[i] = http.CanonicalHeaderKey(h)
+	}
+
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := ""
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			// the response differs by origin, caches must not serve it to other origins
+			h.Add("Vary", "Origin")
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !cfg.allowedOrigin(r, origin) {
+				if preflight && !cfg.PassthroughPreflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if slices.Contains(cfg.AllowedOrigins, "*") {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposeHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+			headers, ok := cfg.allowedHeaders(r.Header.Get("Access-Control-Request-Headers"))
+			if slices.Contains(cfg.AllowedMethods, method) && ok {
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+				if headers != "" {
+					h.Set("Access-Control-Allow-Headers", headers)
+				}
+				if maxAge != "" {
+					h.Set("Access-Control-Max-Age", maxAge)
+				}
+			}
+
+			if cfg.PassthroughPreflight {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allowedOrigin reports whether origin may access the resource.
+func (cfg CORSConfig) allowedOrigin(r *http.Request, origin string) bool {
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) || matchWildcardOrigin(allowed, origin) {
+			return true
+		}
+	}
+	return cfg.AllowOrigin != nil && cfg.AllowOrigin(r, origin)
+}
+
+// matchWildcardOrigin matches origin against a pattern like "https://*.example.com".
+// The wildcard matches one or more subdomain labels, but not the bare domain.
+func matchWildcardOrigin(pattern, origin string) bool {
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(u.Scheme, scheme) {
+		return false
+	}
+
+	return strings.HasSuffix(strings.ToLower(u.Host), "."+strings.ToLower(host))
+}
+
+// allowedHeaders returns the value for Access-Control-Allow-Headers, or false if a
+// requested header is not allowed.
+func (cfg CORSConfig) allowedHeaders(requested string) (string, bool) {
+	if strings.TrimSpace(requested) == "" {
+		return "", true
+	}
+	if slices.Contains(cfg.AllowedHeaders, "*") {
+		// "*" is a literal header name for requests with credentials, echo the request instead
+		return requested, true
+	}
+
+	for _, name := range strings.Split(requested, ",") {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name != "" && !slices.Contains(cfg.AllowedHeaders, name) {
+			return "", false
+		}
+	}
+	return requested, true
+}
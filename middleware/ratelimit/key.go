@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// KeyFunc returns the key a request is counted under. An empty key skips the limit.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by the client IP. trustedHops is the number of proxies in front of
+// the server that append to the X-Forwarded-For header. The client IP is then the
+// trustedHops-th address counted from the right, since the addresses left of it are
+// chosen by the client. Zero uses the address of the connection.
+func ByIP(trustedHops int) KeyFunc {
+	return func(r *http.Request) string {
+		return "ip:" + clientIP(r, trustedHops)
+	}
+}
+
+// ByUser keys requests by the user ID returned by user and falls back to fallback for
+// anonymous requests, for which user returns "". With the auth module:
+//
+//	ratelimit.ByUser(func(r *http.Request) string {
+//		if u, ok := auth.CurrentUser(r.Context()); ok {
+//			return u.UserID()
+//		}
+//		return ""
+//	}, ratelimit.ByIP(0))
+func ByUser(user func(r *http.Request) string, fallback KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		if id := user(r); id != "" {
+			return "user:" + id
+		}
+		return fallback(r)
+	}
+}
+
+// ByRoute scopes key to the matched route pattern, so each route has its own quota.
+// Outside a http.ServeMux route the path is used.
+func ByRoute(key KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		k := key(r)
+		if k == "" {
+			return ""
+		}
+
+		route := r.Pattern
+		if route == "" {
+			route = r.Method + " " + r.URL.Path
+		}
+		return "route:" + route + "|" + k
+	}
+}
+
+// ByHeader keys requests by the value of a header, e.g. an API key. Requests without
+// the header are not limited, combine it with Compose to limit them by another key.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return "header:" + name + ":" + v
+		}
+		return ""
+	}
+}
+
+// Compose returns the first non-empty key of keys.
+func Compose(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, key := range keys {
+			if k := key(r); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+// clientIP returns the IP of the client that sent r through trustedHops proxies.
+func clientIP(r *http.Request, trustedHops int) string {
+	if trustedHops > 0 {
+		// proxies may append a new header line instead of extending the last one
+		var addrs []string
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			addrs = append(addrs, strings.Split(v, ",")...)
+		}
+		if len(addrs) > 0 {
+			// fewer entries than hops means the request skipped an outer proxy, the
+			// leftmost entry was then still added by a trusted one
+			i := max(len(addrs)-trustedHops, 0)
+			if ip := net.ParseIP(strings.TrimSpace(addrs[i])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
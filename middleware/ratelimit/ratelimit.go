@@ -0,0 +1,140 @@
+// Package ratelimit provides a rate limiting middleware with token bucket and sliding
+// window algorithms and pluggable stores.
+//
+//	login := ratelimit.Limit(ratelimit.Config{
+//		Rate: ratelimit.PerMinute(5),
+//		Key:  ratelimit.ByRoute(ratelimit.ByIP(0)),
+//	})
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/up1io/muxo"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/middleware"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Algorithm selects how requests are counted.
+type Algorithm int
+
+const (
+	// TokenBucket refills Requests tokens per Period and allows bursts up to Burst requests.
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Requests per Period, weighting the previous window by its overlap
+	// with the sliding window. It does not allow bursts beyond the limit.
+	SlidingWindow
+)
+
+// Rate is the number of requests allowed per period.
+type Rate struct {
+	Requests int
+	Period   time.Duration
+	// Burst is the capacity of a token bucket, defaults to Requests
+	Burst int
+}
+
+// PerSecond returns a rate of n requests per second.
+func PerSecond(n int) Rate {
+	return Rate{Requests: n, Period: time.Second}
+}
+
+// PerMinute returns a rate of n requests per minute.
+func PerMinute(n int) Rate {
+	return Rate{Requests: n, Period: time.Minute}
+}
+
+// PerHour returns a rate of n requests per hour.
+func PerHour(n int) Rate {
+	return Rate{Requests: n, Period: time.Hour}
+}
+
+// Result is the outcome of a request counted by a Store.
+type Result struct {
+	Allowed bool
+	// Limit is the number of requests allowed per period
+	Limit int
+	// Remaining is the number of requests left in the current period
+	Remaining int
+	// Reset is the time until the quota is fully restored
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero if it is allowed
+	RetryAfter time.Duration
+}
+
+// Store counts requests. Shared stores like Redis implement both algorithms atomically,
+// so every instance of an application enforces the same limit.
+type Store interface {
+	Take(ctx context.Context, key string, rate Rate, algorithm Algorithm) (Result, error)
+}
+
+// Config configures the Limit middleware.
+type Config struct {
+	Rate      Rate
+	Algorithm Algorithm
+	// Key identifies the client, defaults to ByIP(0). Requests with an empty key are not limited.
+	Key KeyFunc
+	// Store defaults to a MemoryStore of this limiter
+	Store Store
+	// Policy names the limit in the RateLimit-Policy header, defaults to the rate
+	Policy string
+}
+
+// Limit creates a middleware that rejects requests exceeding cfg.Rate with 429.
+// Every response carries the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+// RateLimit-Policy headers, rejected responses additionally Retry-After.
+func Limit(cfg Config) middleware.Middleware {
+	if cfg.Rate.Requests <= 0 || cfg.Rate.Period <= 0 {
+		panic("ratelimit: rate must allow at least one request per positive period")
+	}
+	if cfg.Key == nil {
+		cfg.Key = ByIP(0)
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore(time.Minute)
+	}
+	if cfg.Policy == "" {
+		cfg.Policy = fmt.Sprintf("%d;w=%d", cfg.Rate.Requests, int(math.Ceil(cfg.Rate.Period.Seconds())))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cfg.Key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := cfg.Store.Take(r.Context(), key, cfg.Rate, cfg.Algorithm)
+			if err != nil {
+				// an unavailable store must not take the application down, let the request pass
+				logger.FromContext(r.Context()).Error("rate limit: %s", err.Error())
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", cfg.Policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+				muxo.WriteError(w, r, muxo.NewProblem(http.StatusTooManyRequests, "Too many requests, please try again later."))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds rounds d up to whole seconds, as required by the rate limit headers.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// entry is the state of one key in the MemoryStore.
+type entry struct {
+	// token bucket
+	tokens float64
+	last   time.Time
+	// sliding window
+	windowStart time.Time
+	prevCount   int
+	currCount   int
+	// expires is when the entry has no effect anymore and can be evicted
+	expires time.Time
+}
+
+// MemoryStore counts requests in process memory. Limits are enforced per instance.
+// Keys whose quota is fully restored are evicted periodically.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryStore creates a MemoryStore that evicts idle keys every cleanupInterval.
+// A zero interval disables the eviction.
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		entries: make(map[string]*entry),
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+
+	if cleanupInterval > 0 {
+		go s.cleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(ctx context.Context, key string, rate Rate, algorithm Algorithm) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	e, ok := s.entries[key]
+	if !ok {
+		e = &entry{}
+		s.entries[key] = e
+	}
+
+	if algorithm == SlidingWindow {
+		return e.slidingWindow(now, rate), nil
+	}
+	return e.tokenBucket(now, rate), nil
+}
+
+// tokenBucket counts a request with the token bucket algorithm.
+func (e *entry) tokenBucket(now time.Time, rate Rate) Result {
+	capacity := float64(rate.Burst)
+	if capacity <= 0 {
+		capacity = float64(rate.Requests)
+	}
+	// tokens per second
+	refill := float64(rate.Requests) / rate.Period.Seconds()
+
+	if e.last.IsZero() {
+		e.tokens = capacity
+	} else {
+		e.tokens = math.Min(capacity, e.tokens+now.Sub(e.last).Seconds()*refill)
+	}
+	e.last = now
+
+	res := Result{Limit: int(capacity)}
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsDuration((1 - e.tokens) / refill)
+	}
+
+	res.Remaining = int(e.tokens)
+	res.Reset = secondsDuration((capacity - e.tokens) / refill)
+	e.expires = now.Add(res.Reset)
+	return res
+}
+
+// slidingWindow counts a request with the sliding window counter algorithm.
+func (e *entry) slidingWindow(now time.Time, rate Rate) Result {
+	period := rate.Period
+
+	switch elapsed := now.Sub(e.windowStart); {
+	case e.windowStart.IsZero() || elapsed >= 2*period:
+		e.windowStart = now.Truncate(period)
+		e.prevCount, e.currCount = 0, 0
+	case elapsed >= period:
+		e.windowStart = e.windowStart.Add(period)
+		e.prevCount, e.currCount = e.currCount, 0
+	}
+
+	elapsed := now.Sub(e.windowStart)
+	weight := 1 - elapsed.Seconds()/period.Seconds()
+	used := float64(e.prevCount)*weight + float64(e.currCount)
+
+	res := Result{Limit: rate.Requests, Reset: period - elapsed}
+	if used+1 <= float64(rate.Requests) {
+		e.currCount++
+		used++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = e.retryAfter(elapsed, rate)
+	}
+
+	res.Remaining = max(0, rate.Requests-int(math.Ceil(used)))
+	if e.prevCount > 0 {
+		// the previous window still counts until the current one ends
+		res.Reset = 2*period - elapsed
+	}
+	e.expires = e.windowStart.Add(2 * period)
+	return res
+}
+
+// retryAfter returns the time until the weighted count drops enough for one more request.
+func (e *entry) retryAfter(elapsed time.Duration, rate Rate) time.Duration {
+	period := rate.Period
+	free := float64(rate.Requests - 1 - e.currCount)
+	if free < 0 || e.prevCount == 0 {
+		// the current window is full, its count only decays in the next window
+		return period - elapsed
+	}
+
+	// solve prevCount * (1 - (elapsed+t)/period) <= free for t
+	t := period.Seconds()*(1-free/float64(e.prevCount)) - elapsed.Seconds()
+	return secondsDuration(math.Max(0, t))
+}
+
+// Close stops the background eviction.
+func (s *MemoryStore) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// cleanup evicts expired keys every interval until Close is called.
+func (s *MemoryStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			now := s.now()
+			for key, e := range s.entries {
+				if now.After(e.expires) {
+					delete(s.entries, key)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// secondsDuration converts fractional seconds to a duration.
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/render"
+	"net/http"
+	"os"
+	goruntime "runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// sourceContext is the number of lines shown around the panicking line on the debug page.
+const sourceContext = 5
+
+// maxDebugFrames limits the number of frames with source snippets on the debug page.
+const maxDebugFrames = 10
+
+// RecoverConfig configures the Recover middleware.
+type RecoverConfig struct {
+	// Dev renders a detailed debug page with the stack trace and source snippet instead of the error page
+	Dev bool
+	// ErrorPage renders the HTML error page, defaults to render.ErrorPage
+	ErrorPage func(status int, message string) templ.Component
+}
+
+// Recover creates a middleware that recovers from panics in later handlers.
+// The panic and its stack are logged through the request logger and a 500 response is written,
+// as JSON for clients preferring JSON and as an HTML error page otherwise. Panics after the
+// header was written abort the connection instead.
+func Recover(cfg RecoverConfig) Middleware {
+	if cfg.ErrorPage == nil {
+		cfg.ErrorPage = render.ErrorPage
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := newResponseWriter(w)
+
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// the server uses this sentinel to abort a response silently
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				stack := debug.Stack()
+				log := logger.FromContext(r.Context())
+				log.Error("panic: %v\n%s", v, stack)
+
+				if rw.wroteHeader {
+					// the response is already on its way, abort the connection so the client
+					// sees an error instead of a truncated body that looks complete
+					panic(http.ErrAbortHandler)
+				}
+
+				cfg.respond(rw, r, v, stack)
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// respond writes the 500 response for a recovered panic.
+func (cfg RecoverConfig) respond(w http.ResponseWriter, r *http.Request, v any, stack []byte) {
+	status := http.StatusInternalServerError
+	requestID, _ := RequestIDFromContext(r.Context())
+	log := logger.FromContext(r.Context())
+
+	if render.PrefersJSON(r) {
+		problem := render.NewProblem(status, "")
+		if cfg.Dev {
+			problem.Detail = fmt.Sprint(v)
+		}
+		if requestID != "" {
+			problem.With("request_id", requestID)
+		}
+		problem.Instance = r.URL.Path
+		if err := render.ProblemJSON(w, problem); err != nil {
+			log.Error("failed to write panic response: %s", err.Error())
+		}
+		return
+	}
+
+	page := cfg.ErrorPage(status, "")
+	if cfg.Dev {
+		page = render.DebugPage(render.DebugInfo{
+			Status:    status,
+			Message:   fmt.Sprint(v),
+			RequestID: requestID,
+			Method:    r.Method,
+			URL:       r.URL.String(),
+			Frames:    panicFrames(),
+			Stack:     string(stack),
+		})
+	}
+
+	if err := render.HTML(w, r, status, page); err != nil {
+		log.Error("failed to write panic response: %s", err.Error())
+	}
+}
+
+// panicFrames returns the frames of the panicking goroutine, starting at the panic site.
+// It must be called from the deferred function that recovered the panic.
+func panicFrames() []render.Frame {
+	pcs := make([]uintptr, 64)
+	n := goruntime.Callers(0, pcs)
+	frames := goruntime.CallersFrames(pcs[:n])
+
+	var out []render.Frame
+	panicking := false
+	for {
+		f, more := frames.Next()
+
+		switch {
+		case f.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && !strings.HasPrefix(f.Function, "runtime.") && len(out) < maxDebugFrames:
+			out = append(out, render.Frame{
+				Function: f.Function,
+				File:     f.File,
+				Line:     f.Line,
+				Source:   sourceSnippet(f.File, f.Line),
+			})
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return out
+}
+
+// sourceSnippet reads the lines around line from file.
+func sourceSnippet(file string, line int) []render.SourceLine {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var out []render.SourceLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if n < line-sourceContext {
+			continue
+		}
+		if n > line+sourceContext {
+			break
+		}
+		out = append(out, render.SourceLine{Number: n, Text: scanner.Text(), Current: n == line})
+	}
+
+	return out
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/up1io/muxo/logger"
+	"net/http"
+)
+
+// RequestIDHeader is the header used to read and propagate the request id.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limits the length of request ids accepted from clients.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request id stored in ctx, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// NewRequestIDContext returns a new Context that carries the request id.
+func NewRequestIDContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID creates a middleware that reads the X-Request-ID header or generates a new id.
+// The id is echoed in the response and stored in the request context together with a
+// request-scoped logger derived from logger.FromContext, so every line carries the id.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := NewRequestIDContext(r.Context(), id)
+			ctx = logger.NewContext(ctx, logger.With(logger.FromContext(ctx), "request_id", id))
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// newRequestID returns a random 128-bit hex encoded id.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether a client supplied id is safe to reuse.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/up1io/muxo/logger"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NoncePlaceholder is replaced with the per-request nonce in SecurityConfig.ContentSecurityPolicy.
+const NoncePlaceholder = "{nonce}"
+
+// CSPReportPath is the conventional path to mount CSPReportHandler at.
+const CSPReportPath = "/csp-report"
+
+// maxCSPReportSize limits the size of CSP violation reports.
+const maxCSPReportSize = 64 << 10
+
+// SecurityConfig configures the SecurityHeaders middleware. Empty fields omit their header.
+type SecurityConfig struct {
+	// HSTSMaxAge sets Strict-Transport-Security, zero omits it
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains adds includeSubDomains to Strict-Transport-Security
+	HSTSIncludeSubdomains bool
+	// HSTSPreload adds preload to Strict-Transport-Security
+	HSTSPreload bool
+	// NoSniff sets X-Content-Type-Options: nosniff
+	NoSniff bool
+	// ReferrerPolicy sets Referrer-Policy
+	ReferrerPolicy string
+	// PermissionsPolicy sets Permissions-Policy, e.g. "camera=(), microphone=()"
+	PermissionsPolicy string
+	// CrossOriginOpenerPolicy sets Cross-Origin-Opener-Policy
+	CrossOriginOpenerPolicy string
+	// CrossOriginEmbedderPolicy sets Cross-Origin-Embedder-Policy
+	CrossOriginEmbedderPolicy string
+	// ContentSecurityPolicy sets Content-Security-Policy. Every NoncePlaceholder is replaced
+	// with the nonce of the request.
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only, so violations
+	// are reported but not enforced
+	CSPReportOnly bool
+	// CSPReportURI is the URL violations are reported to, e.g. CSPReportPath
+	CSPReportURI string
+}
+
+// DefaultCSP allows resources from the own origin and inline scripts and styles carrying
+// the request nonce.
+const DefaultCSP = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; " +
+	"img-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'self'"
+
+// DefaultSecurityConfig returns a configuration with strict defaults suitable for most
+// server rendered applications.
+func DefaultSecurityConfig() SecurityConfig {
+	return SecurityConfig{
+		HSTSMaxAge:              365 * 24 * time.Hour,
+		HSTSIncludeSubdomains:   true,
+		NoSniff:                 true,
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+		PermissionsPolicy:       "camera=(), microphone=(), geolocation=()",
+		CrossOriginOpenerPolicy: "same-origin",
+		ContentSecurityPolicy:   DefaultCSP,
+	}
+}
+
+type cspNonceKey struct{}
+
+// CSPNonceFromContext returns the CSP nonce of the request that ctx belongs to, if any.
+// templ reads the same nonce through templ.GetNonce for the scripts it generates.
+func CSPNonceFromContext(ctx context.Context) (string, bool) {
+	nonce, ok := ctx.Value(cspNonceKey{}).(string)
+	return nonce, ok
+}
+
+// NewCSPNonceContext returns a new Context that carries the CSP nonce for both
+// CSPNonceFromContext and templ.
+func NewCSPNonceContext(ctx context.Context, nonce string) context.Context {
+	ctx = context.WithValue(ctx, cspNonceKey{}, nonce)
+	return templ.WithNonce(ctx, nonce)
+}
+
+// SecurityHeaders creates a middleware that sets the security headers of cfg on every
+// response. A random nonce is generated per request when the policy contains NoncePlaceholder.
+func SecurityHeaders(cfg SecurityConfig) Middleware {
+	static := cfg.staticHeaders()
+	useNonce := strings.Contains(cfg.ContentSecurityPolicy, NoncePlaceholder)
+
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	policy := cfg.ContentSecurityPolicy
+	if policy != "" && cfg.CSPReportURI != "" {
+		policy += "; report-uri " + cfg.CSPReportURI + "; report-to csp-endpoint"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			for name, value := range static {
+				h.Set(name, value)
+			}
+
+			if policy != "" {
+				csp := policy
+				if useNonce {
+					nonce := newNonce()
+					csp = strings.ReplaceAll(csp, NoncePlaceholder, nonce)
+					r = r.WithContext(NewCSPNonceContext(r.Context(), nonce))
+				}
+				h.Set(cspHeader, csp)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// staticHeaders returns the headers that are the same for every request.
+func (cfg SecurityConfig) staticHeaders() map[string]string {
+	headers := make(map[string]string)
+
+	if cfg.HSTSMaxAge > 0 {
+		hsts := fmt.Sprintf("max-age=%d", int64(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+		headers["Strict-Transport-Security"] = hsts
+	}
+	if cfg.NoSniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	if cfg.ReferrerPolicy != "" {
+		headers["Referrer-Policy"] = cfg.ReferrerPolicy
+	}
+	if cfg.PermissionsPolicy != "" {
+		headers["Permissions-Policy"] = cfg.PermissionsPolicy
+	}
+	if cfg.CrossOriginOpenerPolicy != "" {
+		headers["Cross-Origin-Opener-Policy"] = cfg.CrossOriginOpenerPolicy
+	}
+	if cfg.CrossOriginEmbedderPolicy != "" {
+		headers["Cross-Origin-Embedder-Policy"] = cfg.CrossOriginEmbedderPolicy
+	}
+	if cfg.ContentSecurityPolicy != "" && cfg.CSPReportURI != "" {
+		headers["Reporting-Endpoints"] = fmt.Sprintf(`csp-endpoint="%s"`, cfg.CSPReportURI)
+	}
+
+	return headers
+}
+
+// newNonce returns a random 128-bit base64 encoded nonce.
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// cspViolation holds the fields of a violation report that are logged.
+// The legacy report-uri format uses kebab-case, the Reporting API camelCase.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	LegacySourceFile   string `json:"source-file"`
+	LegacyLineNumber   int    `json:"line-number"`
+	DocumentURL        string `json:"documentURL"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	Disposition        string `json:"disposition"`
+}
+
+// CSPReportHandler returns a handler collecting CSP violation reports sent through
+// report-uri (application/csp-report) and the Reporting API (application/reports+json).
+// Each violation is logged as warning through logger.FromContext. Browsers send reports
+// without CSRF tokens, so mount it outside of the csrf middleware.
+func CSPReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		log := logger.FromContext(r.Context())
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportSize))
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		violations, err := parseCSPReports(body)
+		if err != nil {
+			log.Debug("invalid csp report: %s", err.Error())
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		for _, v := range violations {
+			document, blocked, directive := v.DocumentURI, v.BlockedURI, v.ViolatedDirective
+			source, line := v.LegacySourceFile, v.LegacyLineNumber
+			if document == "" {
+				document, blocked, directive = v.DocumentURL, v.BlockedURL, v.EffectiveDirective
+				source, line = v.SourceFile, v.LineNumber
+			}
+			log.Warn("csp violation: directive=%q blocked=%q document=%q source=%q line=%d disposition=%q",
+				directive, blocked, document, source, line, v.Disposition)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// parseCSPReports decodes a report in either the legacy or the Reporting API format.
+func parseCSPReports(body []byte) ([]cspViolation, error) {
+	body = []byte(strings.TrimSpace(string(body)))
+
+	if len(body) > 0 && body[0] == '[' {
+		var reports []struct {
+			Type string       `json:"type"`
+			Body cspViolation `json:"body"`
+		}
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+
+		var violations []cspViolation
+		for _, report := range reports {
+			if report.Type == "csp-violation" {
+				violations = append(violations, report.Body)
+			}
+		}
+		return violations, nil
+	}
+
+	var legacy struct {
+		Report cspViolation `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	return []cspViolation{legacy.Report}, nil
+}
@@ -0,0 +1,247 @@
+// Package auth provides authentication utilities.
+//
+// Users are signed into the session, so the session middleware must run before the
+// authentication middleware:
+//
+//	app := muxo.NewApp(muxo.WithAdditionalMiddleware(
+//		sessions.Middleware(),
+//		middleware.WithAuthentication(users),
+//	))
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/up1io/muxo"
+	"github.com/up1io/muxo/module/auth/middleware"
+	"github.com/up1io/muxo/render"
+	"github.com/up1io/muxo/session"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// CurrentUser returns the user signed in for the request that ctx belongs to.
+// It uses the user resolved by the authentication middleware.
+func CurrentUser(ctx context.Context) (middleware.User, bool) {
+	return middleware.UserFromContext(ctx)
+}
+
+// Login signs user into the session of ctx. The session token is renewed to prevent
+// session fixation.
+func Login(ctx context.Context, user middleware.User) error {
+	if err := session.Renew(ctx); err != nil {
+		return err
+	}
+	return session.Set(ctx, middleware.SessionKey, user.UserID())
+}
+
+// Logout destroys the session of ctx.
+func Logout(ctx context.Context) error {
+	return session.Destroy(ctx)
+}
+
+// PasswordStore looks up users and their password hashes by login name.
+type PasswordStore interface {
+	// FindLogin returns the user and password hash for login, or middleware.ErrUserNotFound
+	FindLogin(ctx context.Context, login string) (user middleware.User, hash string, err error)
+}
+
+// LoginConfig configures the LoginHandler.
+type LoginConfig struct {
+	// SuccessURL is the redirect target after signing in without "next" parameter, defaults to "/"
+	SuccessURL string
+	// FailureURL is the redirect target of browsers after a failed sign in, usually the login
+	// page, which shows the "error" flash message. Defaults to the referring page.
+	FailureURL string
+	// Rehash is called with a new hash when the stored hash uses outdated parameters or bcrypt
+	Rehash func(ctx context.Context, user middleware.User, hash string) error
+}
+
+// errInvalidCredentials is the message for failed sign ins. It does not tell whether the
+// login or the password was wrong.
+const errInvalidCredentials = "Invalid login or password"
+
+// dummyHash is verified for unknown logins, so the response time does not reveal them.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("muxo-dummy-password")
+	return hash
+})
+
+// LoginHandler handles the POST of a login form with the fields "login", "password" and
+// the optional "next". Clients preferring JSON receive 204 or 401 instead of redirects.
+func LoginHandler(store PasswordStore, cfg LoginConfig) muxo.HandlerFunc {
+	if cfg.SuccessURL == "" {
+		cfg.SuccessURL = "/"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		login := strings.TrimSpace(r.PostFormValue("login"))
+		password := r.PostFormValue("password")
+
+		user, hash, err := store.FindLogin(r.Context(), login)
+		if errors.Is(err, middleware.ErrUserNotFound) {
+			_, _ = CheckPassword(password, dummyHash())
+			return loginFailed(w, r, cfg)
+		}
+		if err != nil {
+			return fmt.Errorf("find login: %w", err)
+		}
+
+		ok, err := CheckPassword(password, hash)
+		if err != nil {
+			return fmt.Errorf("check password: %w", err)
+		}
+		if !ok {
+			return loginFailed(w, r, cfg)
+		}
+
+		if cfg.Rehash != nil && NeedsRehash(hash) {
+			if hash, err = HashPassword(password); err == nil {
+				err = cfg.Rehash(r.Context(), user, hash)
+			}
+			if err != nil {
+				return fmt.Errorf("rehash password: %w", err)
+			}
+		}
+
+		if err := Login(r.Context(), user); err != nil {
+			return fmt.Errorf("login: %w", err)
+		}
+
+		if render.PrefersJSON(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+		http.Redirect(w, r, safeRedirect(r.PostFormValue("next"), cfg.SuccessURL), http.StatusSeeOther)
+		return nil
+	}
+}
+
+// loginFailed responds to a failed sign in.
+func loginFailed(w http.ResponseWriter, r *http.Request, cfg LoginConfig) error {
+	if render.PrefersJSON(r) {
+		return muxo.NewHTTPError(http.StatusUnauthorized, errInvalidCredentials, nil)
+	}
+
+	if err := session.AddFlash(r.Context(), "error", errInvalidCredentials); err != nil {
+		return err
+	}
+	target := cfg.FailureURL
+	if target == "" {
+		target = "/"
+		if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host {
+			target = safeRedirect(ref.RequestURI(), target)
+		}
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+	return nil
+}
+
+// LogoutHandler signs the user out and redirects to redirectURL. Register it for POST,
+// so it is protected against cross-site requests by the csrf middleware.
+func LogoutHandler(redirectURL string) muxo.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := Logout(r.Context()); err != nil {
+			return fmt.Errorf("logout: %w", err)
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return nil
+	}
+}
+
+// oauthSession is the session state of a pending provider login.
+type oauthSession struct {
+	AuthRequest
+	Next string
+}
+
+// oauthKey returns the session key of pending logins with provider p.
+func oauthKey(p Provider) string {
+	return "auth.oauth." + p.Name()
+}
+
+// ProviderLoginHandler starts a sign in with p by redirecting to its consent page.
+// The "next" query parameter is kept for the redirect after the callback.
+func ProviderLoginHandler(p Provider) muxo.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		req := AuthRequest{State: randomString(), Nonce: randomString(), Verifier: randomString()}
+
+		pending := oauthSession{AuthRequest: req, Next: r.URL.Query().Get("next")}
+		if err := session.Set(r.Context(), oauthKey(p), pending); err != nil {
+			return fmt.Errorf("start %s login: %w", p.Name(), err)
+		}
+
+		http.Redirect(w, r, p.AuthCodeURL(req), http.StatusFound)
+		return nil
+	}
+}
+
+// CallbackHandler completes a sign in with p. resolve maps the identity to a local user,
+// creating or linking accounts as needed. The user is then signed in and redirected to the
+// "next" URL of the login or successURL.
+func CallbackHandler(p Provider, successURL string, resolve func(ctx context.Context, id *Identity) (middleware.User, error)) muxo.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		pending, ok := session.Get[oauthSession](r.Context(), oauthKey(p))
+		if !ok {
+			return muxo.NewHTTPError(http.StatusBadRequest, "No sign in in progress", nil)
+		}
+		session.Delete(r.Context(), oauthKey(p))
+
+		q := r.URL.Query()
+		if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(pending.State)) != 1 {
+			return muxo.NewHTTPError(http.StatusBadRequest, "Invalid sign in state", nil)
+		}
+		if e := q.Get("error"); e != "" {
+			return muxo.NewHTTPError(http.StatusUnauthorized, "Sign in was denied", fmt.Errorf("%s: %s", e, q.Get("error_description")))
+		}
+
+		id, err := p.Exchange(r.Context(), q.Get("code"), pending.AuthRequest)
+		if err != nil {
+			return muxo.NewHTTPError(http.StatusUnauthorized, "Sign in failed", err)
+		}
+
+		user, err := resolve(r.Context(), id)
+		if err != nil {
+			return fmt.Errorf("resolve %s identity: %w", p.Name(), err)
+		}
+		if err := Login(r.Context(), user); err != nil {
+			return fmt.Errorf("login: %w", err)
+		}
+
+		http.Redirect(w, r, safeRedirect(pending.Next, successURL), http.StatusSeeOther)
+		return nil
+	}
+}
+
+// safeRedirect returns target if it is a local path, fallback otherwise. It prevents open
+// redirects through the "next" parameter. Browsers drop tabs and newlines from URLs and
+// treat backslashes as slashes, so "/\t/evil.com" and "/\\evil.com" are rejected as well.
+func safeRedirect(target, fallback string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		return fallback
+	}
+	if strings.ContainsFunc(target, func(c rune) bool { return c == '\\' || unicode.IsControl(c) }) {
+		return fallback
+	}
+	if u, err := url.Parse(target); err != nil || u.Scheme != "" || u.Host != "" {
+		return fallback
+	}
+	return target
+}
+
+// randomString returns 32 random bytes encoded for use in URLs.
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// rand.Read never fails on supported platforms
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,133 @@
+// Package middleware provides authentication middleware for HTTP servers.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/up1io/muxo"
+	"github.com/up1io/muxo/htmx"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/middleware"
+	"github.com/up1io/muxo/render"
+	"github.com/up1io/muxo/session"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// SessionKey is the session key holding the id of the signed in user.
+const SessionKey = "auth.user_id"
+
+// ErrUserNotFound is returned by a UserStore for unknown users.
+var ErrUserNotFound = errors.New("auth: user not found")
+
+// User is an authenticated user.
+type User interface {
+	// UserID returns the stable id stored in the session
+	UserID() string
+	// Roles returns the roles checked by RequireRole
+	Roles() []string
+}
+
+// UserStore looks up users by id.
+type UserStore interface {
+	FindUser(ctx context.Context, id string) (User, error)
+}
+
+// contextKey is a custom type to avoid collisions in the context values.
+type contextKey string
+
+// UserKey is the key used to store the current user in the request context.
+const UserKey contextKey = "current-user"
+
+// UserFromContext returns the user stored in ctx, if any.
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(UserKey).(User)
+	return u, ok
+}
+
+// NewUserContext returns a new Context that carries the user.
+func NewUserContext(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, UserKey, u)
+}
+
+// WithAuthentication creates a middleware that resolves the user signed into the session
+// through store and stores it in the request context. It must run after the session
+// middleware. Requests without a signed in user pass through anonymously.
+func WithAuthentication(store UserStore) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := session.Get[string](r.Context(), SessionKey)
+			if !ok || id == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := store.FindUser(r.Context(), id)
+			switch {
+			case errors.Is(err, ErrUserNotFound):
+				// the user was deleted, drop the stale session value
+				session.Delete(r.Context(), SessionKey)
+			case err != nil:
+				muxo.WriteError(w, r, err)
+				return
+			default:
+				r = r.WithContext(NewUserContext(r.Context(), user))
+				r = r.WithContext(logger.NewContext(r.Context(), logger.With(logger.FromContext(r.Context()), "user_id", id)))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAuth creates a middleware that rejects anonymous requests. Browsers are redirected
+// to loginURL with the requested URL in the "next" query parameter, other clients and
+// requests with an empty loginURL receive 401.
+func RequireAuth(loginURL string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := UserFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if loginURL == "" || render.PrefersJSON(r) {
+				w.Header().Set("WWW-Authenticate", `Cookie realm="muxo"`)
+				muxo.WriteError(w, r, muxo.NewHTTPError(http.StatusUnauthorized, "Authentication required", nil))
+				return
+			}
+
+			target := loginURL + "?next=" + url.QueryEscape(r.URL.RequestURI())
+			if htmx.IsRequest(r) {
+				// htmx follows redirects with ajax, make it load the login page instead
+				htmx.Redirect(w, target)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, target, http.StatusSeeOther)
+		})
+	}
+}
+
+// RequireRole creates a middleware that only admits users having at least one of roles.
+// Anonymous requests receive 401, users without the roles 403. Combine it with RequireAuth
+// to redirect anonymous browsers to the login page.
+func RequireRole(roles ...string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := UserFromContext(r.Context())
+			if !ok {
+				muxo.WriteError(w, r, muxo.NewHTTPError(http.StatusUnauthorized, "Authentication required", nil))
+				return
+			}
+
+			if !slices.ContainsFunc(user.Roles(), func(role string) bool { return slices.Contains(roles, role) }) {
+				muxo.WriteError(w, r, muxo.NewHTTPError(http.StatusForbidden, "Permission denied", nil))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidIDToken is returned when an ID token fails verification.
+var ErrInvalidIDToken = errors.New("auth: invalid id token")
+
+// clockSkew is the tolerance for the time claims of ID tokens.
+const clockSkew = time.Minute
+
+// OIDCConfig configures an OpenID Connect provider.
+type OIDCConfig struct {
+	// Name identifies the provider, e.g. "google"
+	Name string
+	// Issuer is the issuer URL, the endpoints are discovered from its
+	// /.well-known/openid-configuration document
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the absolute URL of the callback handler
+	RedirectURL string
+	// Scopes defaults to openid, email and profile
+	Scopes []string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// OIDCProvider signs users in with OpenID Connect. The identity is taken from the ID
+// token, which is verified against the keys published by the issuer. Only RS256 signed
+// tokens are supported.
+type OIDCProvider struct {
+	name   string
+	issuer string
+	oauth  OAuth2Config
+
+	jwksURL string
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+}
+
+// discovery is the subset of the OpenID Provider Metadata used by OIDCProvider.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDCProvider discovers the endpoints of cfg.Issuer and creates the provider.
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (*OIDCProvider, error) {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p := &OIDCProvider{
+		name:   cfg.Name,
+		issuer: strings.TrimSuffix(cfg.Issuer, "/"),
+		oauth: OAuth2Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			HTTPClient:   cfg.HTTPClient,
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d discovery
+	if err := p.oauth.do(req, &d); err != nil {
+		return nil, fmt.Errorf("auth: oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("auth: oidc discovery: issuer %q does not match %q", d.Issuer, cfg.Issuer)
+	}
+
+	p.oauth.AuthURL = d.AuthorizationEndpoint
+	p.oauth.TokenURL = d.TokenEndpoint
+	p.jwksURL = d.JWKSURI
+	return p, nil
+}
+
+// Name implements Provider.
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL implements Provider.
+func (p *OIDCProvider) AuthCodeURL(req AuthRequest) string {
+	return p.oauth.authCodeURL(req, url.Values{"nonce": {req.Nonce}})
+}
+
+// Exchange implements Provider.
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, req AuthRequest) (*Identity, error) {
+	tok, err := p.oauth.exchange(ctx, code, req.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	if tok.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id token", ErrExchange)
+	}
+
+	claims, err := p.verify(ctx, tok.IDToken, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	id := claimsIdentity(claims)
+	id.Provider = p.name
+	return &id, nil
+}
+
+// verify checks the signature and claims of an ID token and returns its claims.
+func (p *OIDCProvider) verify(ctx context.Context, token, nonce string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %w", ErrInvalidIDToken, err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidIDToken, header.Alg)
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrInvalidIDToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrInvalidIDToken, err)
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %w", ErrInvalidIDToken, err)
+	}
+	if err := p.checkClaims(claims, nonce); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims validates the issuer, audience, expiry and nonce of an ID token.
+func (p *OIDCProvider) checkClaims(claims map[string]any, nonce string) error {
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.issuer {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, iss)
+	}
+
+	var aud []string
+	switch v := claims["aud"].(type) {
+	case string:
+		aud = []string{v}
+	case []any:
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				aud = append(aud, s)
+			}
+		}
+	}
+	if !slices.Contains(aud, p.oauth.ClientID) {
+		return fmt.Errorf("%w: audience %v", ErrInvalidIDToken, aud)
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockSkew)) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	}
+
+	if got, _ := claims["nonce"].(string); nonce != "" && got != nonce {
+		return fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return nil
+}
+
+// key returns the signing key kid. The key set is fetched on first use and again when an
+// unknown key id appears, which happens when the issuer rotates its keys.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, kid)
+}
+
+// lookupKey returns the cached key kid. Tokens without key id match a single key.
+func (p *OIDCProvider) lookupKey(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// fetchKeys requests the RSA signing keys of the issuer.
+func (p *OIDCProvider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.oauth.do(req, &set); err != nil {
+		return nil, fmt.Errorf("auth: oidc keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a JWT.
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,326 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/up1io/muxo/module/auth"
+	"github.com/up1io/muxo/module/auth/middleware"
+	"github.com/up1io/muxo/module/auth/oidctest"
+	"github.com/up1io/muxo/session"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// testUser is a user of the test application.
+type testUser struct {
+	id    string
+	roles []string
+}
+
+func (u testUser) UserID() string  { return u.id }
+func (u testUser) Roles() []string { return u.roles }
+
+// users maps the subjects of the identity provider to users.
+type users map[string]testUser
+
+func (s users) FindUser(ctx context.Context, id string) (middleware.User, error) {
+	u, ok := s[id]
+	if !ok {
+		return nil, middleware.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (s users) resolve(ctx context.Context, id *auth.Identity) (middleware.User, error) {
+	return s.FindUser(ctx, id.Subject)
+}
+
+// testApp is an application signing in with an oidctest.Server.
+type testApp struct {
+	*httptest.Server
+	idp      *oidctest.Server
+	provider *auth.OIDCProvider
+	// client keeps the session cookie and does not follow redirects
+	client *http.Client
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	idp := oidctest.NewServer("client", "secret")
+	t.Cleanup(idp.Close)
+
+	app := &testApp{idp: idp}
+	store := users{
+		"admin-1": {id: "admin-1", roles: []string{"admin"}},
+		"user-1":  {id: "user-1", roles: []string{"member"}},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /login", auth.ProviderLoginHandler(app.providerFunc()))
+	mux.Handle("GET /callback", auth.CallbackHandler(app.providerFunc(), "/", store.resolve))
+	mux.Handle("GET /admin", middleware.RequireRole("admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := auth.CurrentUser(r.Context())
+		_, _ = w.Write([]byte(u.UserID()))
+	})))
+
+	sessions := session.New(session.NewMemoryStore(0))
+	app.Server = httptest.NewServer(sessions.Middleware()(middleware.WithAuthentication(store)(mux)))
+	t.Cleanup(app.Close)
+
+	provider, err := auth.NewOIDCProvider(context.Background(), idp.Config(app.URL+"/callback"))
+	if err != nil {
+		t.Fatalf("NewOIDCProvider() error = %v", err)
+	}
+	app.provider = provider
+
+	jar, _ := cookiejar.New(nil)
+	app.client = &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return app
+}
+
+// providerFunc returns a Provider that delegates to app.provider, which is created after
+// the server is started, because its redirect URL contains the server address.
+func (app *testApp) providerFunc() auth.Provider {
+	return lazyProvider{app}
+}
+
+type lazyProvider struct{ app *testApp }
+
+func (p lazyProvider) Name() string { return "oidctest" }
+func (p lazyProvider) AuthCodeURL(req auth.AuthRequest) string {
+	return p.app.provider.AuthCodeURL(req)
+}
+func (p lazyProvider) Exchange(ctx context.Context, code string, req auth.AuthRequest) (*auth.Identity, error) {
+	return p.app.provider.Exchange(ctx, code, req)
+}
+
+// get requests url and returns the response with its body closed.
+func (app *testApp) get(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	resp, err := app.client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	_ = resp.Body.Close()
+	return resp
+}
+
+// location returns the redirect target of resp.
+func location(t *testing.T, resp *http.Response) *url.URL {
+	t.Helper()
+
+	loc, err := resp.Location()
+	if err != nil {
+		t.Fatalf("status %d without redirect: %v", resp.StatusCode, err)
+	}
+	return loc
+}
+
+// signIn follows the login flow for path and returns the response of the callback.
+func (app *testApp) signIn(t *testing.T, path string) *http.Response {
+	t.Helper()
+
+	authorize := location(t, app.get(t, app.URL+path))
+	callback := location(t, app.get(t, authorize.String()))
+	return app.get(t, callback.String())
+}
+
+func TestProviderLoginRedirectsWithPKCE(t *testing.T) {
+	app := newTestApp(t)
+
+	resp := app.get(t, app.URL+"/login?next=/admin")
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	loc := location(t, resp)
+	if got := loc.Scheme + "://" + loc.Host + loc.Path; got != app.idp.URL+"/authorize" {
+		t.Errorf("redirect = %s, want the authorization endpoint", got)
+	}
+
+	q := loc.Query()
+	for param, want := range map[string]string{
+		"client_id":             "client",
+		"redirect_uri":          app.URL + "/callback",
+		"response_type":         "code",
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+	for _, param := range []string{"state", "nonce", "code_challenge"} {
+		if q.Get(param) == "" {
+			t.Errorf("%s is missing", param)
+		}
+	}
+	if !strings.Contains(q.Get("scope"), "openid") {
+		t.Errorf("scope = %q, want openid", q.Get("scope"))
+	}
+}
+
+func TestCallbackSignsInAndChecksRoles(t *testing.T) {
+	tests := []struct {
+		name       string
+		subject    string
+		wantStatus int
+	}{
+		{name: "admin", subject: "admin-1", wantStatus: http.StatusOK},
+		{name: "member", subject: "user-1", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.idp.SetUser(oidctest.User{Subject: tt.subject, Email: tt.subject + "@example.com"})
+
+			if resp := app.get(t, app.URL+"/admin"); resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("anonymous status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+			}
+
+			resp := app.signIn(t, "/login?next=/admin")
+			if resp.StatusCode != http.StatusSeeOther {
+				t.Fatalf("callback status = %d, want %d", resp.StatusCode, http.StatusSeeOther)
+			}
+			if got := location(t, resp).Path; got != "/admin" {
+				t.Errorf("callback redirect = %s, want /admin", got)
+			}
+
+			if resp := app.get(t, app.URL+"/admin"); resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCallbackRejectsInvalidRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		// callback turns the authorization redirect into the callback URL
+		callback   func(app *testApp, authorize *url.URL) string
+		wantStatus int
+	}{
+		{
+			name: "wrong state",
+			callback: func(app *testApp, authorize *url.URL) string {
+				return app.URL + "/callback?code=x&state=forged"
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "denied",
+			callback: func(app *testApp, authorize *url.URL) string {
+				app.idp.SetDeny(true)
+				loc, _ := app.client.Get(authorize.String())
+				_ = loc.Body.Close()
+				target, _ := loc.Location()
+				return target.String()
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown code",
+			callback: func(app *testApp, authorize *url.URL) string {
+				return app.URL + "/callback?code=unknown&state=" + url.QueryEscape(authorize.Query().Get("state"))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+
+			authorize := location(t, app.get(t, app.URL+"/login"))
+			resp := app.get(t, tt.callback(app, authorize))
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp := app.get(t, app.URL+"/admin"); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("user signed in after rejected callback, status = %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestCallbackWithoutLogin(t *testing.T) {
+	app := newTestApp(t)
+
+	resp := app.get(t, app.URL+"/callback?code=x&state=y")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestExchangeVerifiesNonceAndPKCE(t *testing.T) {
+	app := newTestApp(t)
+	req := auth.AuthRequest{State: "state", Nonce: "nonce", Verifier: "verifier-0123456789-0123456789-0123456789"}
+
+	// code returns a fresh authorization code issued for req
+	code := func(t *testing.T) string {
+		t.Helper()
+		loc := location(t, app.get(t, app.provider.AuthCodeURL(req)))
+		return loc.Query().Get("code")
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(r *auth.AuthRequest)
+		wantErr error
+	}{
+		{name: "valid", modify: func(r *auth.AuthRequest) {}},
+		{name: "nonce mismatch", modify: func(r *auth.AuthRequest) { r.Nonce = "other" }, wantErr: auth.ErrInvalidIDToken},
+		{name: "wrong verifier", modify: func(r *auth.AuthRequest) { r.Verifier = "other" }, wantErr: auth.ErrExchange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := req
+			tt.modify(&exchange)
+
+			id, err := app.provider.Exchange(context.Background(), code(t), exchange)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Exchange() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if id.Subject != "user-1" || id.Email != "user@example.com" || id.Provider != "oidctest" {
+				t.Errorf("Exchange() = %+v", id)
+			}
+		})
+	}
+}
+
+func TestAuthCodeURLChallenge(t *testing.T) {
+	app := newTestApp(t)
+	req := auth.AuthRequest{State: "s", Nonce: "n", Verifier: "v"}
+
+	loc, err := url.Parse(app.provider.AuthCodeURL(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(req.Verifier))
+	if got, want := loc.Query().Get("code_challenge"), base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("code_challenge = %q, want %q", got, want)
+	}
+	if got := loc.Query().Get("nonce"); got != req.Nonce {
+		t.Errorf("nonce = %q, want %q", got, req.Nonce)
+	}
+}
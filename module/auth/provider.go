@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrExchange is returned when an authorization code can't be exchanged for an identity.
+var ErrExchange = errors.New("auth: code exchange failed")
+
+// Identity is the user identity asserted by a Provider.
+type Identity struct {
+	// Provider is the name of the provider that asserted the identity
+	Provider string
+	// Subject is the stable id of the user at the provider
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Claims holds all claims or user info fields returned by the provider
+	Claims map[string]any
+}
+
+// AuthRequest holds the per-login secrets of an authorization code flow. The login
+// handler stores them in the session and the callback handler passes them back.
+type AuthRequest struct {
+	// State protects the callback against cross-site request forgery
+	State string
+	// Nonce binds the ID token to the login, only used by OpenID Connect
+	Nonce string
+	// Verifier is the PKCE code verifier
+	Verifier string
+}
+
+// Provider signs users in with an external identity provider using the OAuth2
+// authorization code flow.
+type Provider interface {
+	// Name identifies the provider in URLs and identities, e.g. "google"
+	Name() string
+	// AuthCodeURL returns the URL of the provider's consent page
+	AuthCodeURL(req AuthRequest) string
+	// Exchange redeems the code passed to the callback and returns the identity of the user
+	Exchange(ctx context.Context, code string, req AuthRequest) (*Identity, error)
+}
+
+// OAuth2Config configures the OAuth2 endpoints and client of a provider.
+type OAuth2Config struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	// RedirectURL is the absolute URL of the callback handler
+	RedirectURL string
+	Scopes      []string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// tokenResponse is the response of a token endpoint.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+// client returns the HTTP client for requests to the provider.
+func (c *OAuth2Config) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// authCodeURL returns the consent page URL with PKCE and the given extra parameters.
+func (c *OAuth2Config) authCodeURL(req AuthRequest, extra url.Values) string {
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.ClientID},
+		"redirect_uri":          {c.RedirectURL},
+		"state":                 {req.State},
+		"code_challenge":        {pkceChallenge(req.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if len(c.Scopes) > 0 {
+		v.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	for k, vs := range extra {
+		v[k] = vs
+	}
+
+	sep := "?"
+	if strings.Contains(c.AuthURL, "?") {
+		sep = "&"
+	}
+	return c.AuthURL + sep + v.Encode()
+}
+
+// exchange redeems code at the token endpoint.
+func (c *OAuth2Config) exchange(ctx context.Context, code, verifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURL},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	var tok tokenResponse
+	if err := c.do(req, &tok); err != nil {
+		if tok.Error != "" {
+			return nil, fmt.Errorf("%w: %s: %s", ErrExchange, tok.Error, tok.ErrorDesc)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("%w: no access token", ErrExchange)
+	}
+	return &tok, nil
+}
+
+// do sends req and decodes the JSON response into v. v is decoded for error statuses as
+// well, so OAuth2 error responses are available to the caller.
+func (c *OAuth2Config) do(req *http.Request, v any) error {
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, v)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	return decodeErr
+}
+
+// OAuth2Provider is a plain OAuth2 provider that reads the identity from a user info
+// endpoint, for providers not supporting OpenID Connect.
+type OAuth2Provider struct {
+	OAuth2Config
+	// ProviderName is returned by Name
+	ProviderName string
+	// UserInfoURL is requested with the access token
+	UserInfoURL string
+	// Identity maps the user info response to an identity.
+	// By default the OpenID Connect claim names are used, with "id" as fallback for "sub".
+	Identity func(info map[string]any) Identity
+}
+
+// Name implements Provider.
+func (p *OAuth2Provider) Name() string {
+	return p.ProviderName
+}
+
+// AuthCodeURL implements Provider.
+func (p *OAuth2Provider) AuthCodeURL(req AuthRequest) string {
+	return p.authCodeURL(req, nil)
+}
+
+// Exchange implements Provider.
+func (p *OAuth2Provider) Exchange(ctx context.Context, code string, req AuthRequest) (*Identity, error) {
+	tok, err := p.exchange(ctx, code, req.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fetchUserInfo(ctx, &p.OAuth2Config, p.UserInfoURL, tok.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	mapIdentity := p.Identity
+	if mapIdentity == nil {
+		mapIdentity = claimsIdentity
+	}
+	id := mapIdentity(info)
+	id.Provider = p.ProviderName
+	if id.Subject == "" {
+		return nil, fmt.Errorf("%w: user info without subject", ErrExchange)
+	}
+	return &id, nil
+}
+
+// fetchUserInfo requests the user info endpoint with accessToken.
+func fetchUserInfo(ctx context.Context, c *OAuth2Config, endpoint, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info map[string]any
+	if err := c.do(req, &info); err != nil {
+		return nil, fmt.Errorf("%w: user info: %w", ErrExchange, err)
+	}
+	return info, nil
+}
+
+// claimsIdentity maps OpenID Connect claims to an identity.
+func claimsIdentity(claims map[string]any) Identity {
+	id := Identity{Claims: claims}
+	id.Subject, _ = claims["sub"].(string)
+	if id.Subject == "" {
+		// e.g. GitHub returns a numeric id
+		if v, ok := claims["id"]; ok && v != nil {
+			id.Subject = fmt.Sprint(v)
+		}
+	}
+	id.Email, _ = claims["email"].(string)
+	id.EmailVerified, _ = claims["email_verified"].(bool)
+	id.Name, _ = claims["name"].(string)
+	return id
+}
+
+// pkceChallenge returns the S256 code challenge of verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
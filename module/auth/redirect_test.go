@@ -0,0 +1,29 @@
+package auth
+
+import "testing"
+
+func TestSafeRedirect(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "/admin", want: "/admin"},
+		{target: "/search?q=a%2Fb#top", want: "/search?q=a%2Fb#top"},
+		{target: "", want: "/"},
+		{target: "admin", want: "/"},
+		{target: "https://evil.com", want: "/"},
+		{target: "//evil.com", want: "/"},
+		{target: "/\\evil.com", want: "/"},
+		{target: "\\\\evil.com", want: "/"},
+		{target: "/\t/evil.com", want: "/"},
+		{target: "/\n/evil.com", want: "/"},
+		{target: "/\r\n/evil.com", want: "/"},
+		{target: "/\x00/evil.com", want: "/"},
+	}
+
+	for _, tt := range tests {
+		if got := safeRedirect(tt.target, "/"); got != tt.want {
+			t.Errorf("safeRedirect(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
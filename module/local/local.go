@@ -2,7 +2,9 @@
 package local
 
 import (
+	"context"
 	"github.com/leonelquinteros/gotext"
+	"github.com/up1io/muxo/module/local/middleware"
 	"net/http"
 )
 
@@ -12,6 +14,16 @@ func Text(s string, vars ...interface{}) string {
 	return gotext.Get(s, vars...)
 }
 
+// TextContext returns the localized version of the given string for the language
+// negotiated for the request that ctx belongs to. Unlike Text, it does not depend on
+// the process wide language and is safe to use from concurrent requests.
+func TextContext(ctx context.Context, s string, vars ...interface{}) string {
+	if l, ok := middleware.LocaleFromContext(ctx); ok {
+		return l.Text(s, vars...)
+	}
+	return gotext.Get(s, vars...)
+}
+
 // SetLocal sets the user's preferred language by setting a cookie.
 // This allows users to switch the locale for future requests.
 // The language code should be a valid language tag (e.g., "en", "de", "fr").
@@ -4,6 +4,7 @@ package middleware
 import (
 	"context"
 	"github.com/leonelquinteros/gotext"
+	"github.com/up1io/muxo/locales"
 	"github.com/up1io/muxo/logger"
 	"github.com/up1io/muxo/middleware"
 	"golang.org/x/text/language"
@@ -43,6 +44,53 @@ func NewLanguageContext(ctx context.Context, language string) context.Context {
 	return context.WithValue(ctx, LanguageKey, language)
 }
 
+// LocaleKey is the key used to store the request locale in the request context.
+const LocaleKey contextKey = "current-locale"
+
+// LocaleFromContext returns the locale reader stored in ctx, if any.
+func LocaleFromContext(ctx context.Context) (locales.Reader, bool) {
+	l, ok := ctx.Value(LocaleKey).(locales.Reader)
+	return l, ok
+}
+
+// NewLocaleContext returns a new Context that carries the locale reader.
+func NewLocaleContext(ctx context.Context, l locales.Reader) context.Context {
+	return context.WithValue(ctx, LocaleKey, l)
+}
+
+// localeReader adapts a gotext.Locale to the locales.Reader interface.
+type localeReader struct {
+	*gotext.Locale
+}
+
+// Text returns the localized version of the given string.
+func (l localeReader) Text(s string, vars ...interface{}) string {
+	return l.Get(s, vars...)
+}
+
+// localeCache lazily loads one gotext.Locale per language.
+type localeCache struct {
+	mu      sync.Mutex
+	dir     string
+	domain  string
+	locales map[string]localeReader
+}
+
+// get returns the locale for lang, loading it on first use.
+func (c *localeCache) get(lang string) localeReader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.locales[lang]; ok {
+		return l
+	}
+
+	l := localeReader{gotext.NewLocale(c.dir, lang)}
+	l.AddDomain(c.domain)
+	c.locales[lang] = l
+	return l
+}
+
 // WithLocalization creates a middleware that configures localization based on the provided locales directory.
 // It scans the locales directory for available locales and configures gotext with the default language.
 func WithLocalization(localesDir string) middleware.Middleware {
@@ -69,6 +117,7 @@ func WithLocalization(localesDir string) middleware.Middleware {
 	logger.Info("Available locales: %s", strings.Join(availableLocales, ","))
 
 	var mu sync.Mutex
+	cache := &localeCache{dir: localesDir, domain: domain, locales: make(map[string]localeReader)}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -86,6 +135,7 @@ func WithLocalization(localesDir string) middleware.Middleware {
 			tag, _ := language.MatchStrings(langMatcher, langStr)
 
 			ctx := NewLanguageContext(r.Context(), tag.String())
+			ctx = NewLocaleContext(ctx, cache.get(tag.String()))
 			req := r.WithContext(ctx)
 
 			mu.Lock()
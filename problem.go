@@ -0,0 +1,31 @@
+package muxo
+
+import (
+	"github.com/up1io/muxo/render"
+	"net/http"
+)
+
+// Problem is an RFC 9457 problem details document.
+type Problem = render.Problem
+
+// NewProblem creates a Problem for the given status with the status text as title.
+func NewProblem(status int, detail string) *Problem {
+	return render.NewProblem(status, detail)
+}
+
+// NewValidationProblem creates a 422 Problem carrying the problems returned by DecodeValid
+// in the "errors" extension member.
+func NewValidationProblem(problems map[string]string) *Problem {
+	return NewProblem(http.StatusUnprocessableEntity, "The request contains invalid fields.").
+		With("errors", problems)
+}
+
+// EncodeProblem writes p as an application/problem+json response.
+// If p has no instance, the request path is used. p itself is not modified.
+func EncodeProblem(w http.ResponseWriter, r *http.Request, p *Problem) error {
+	if p.Instance == "" {
+		p = p.Clone()
+		p.Instance = r.URL.Path
+	}
+	return render.ProblemJSON(w, p)
+}
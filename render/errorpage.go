@@ -0,0 +1,101 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"github.com/a-h/templ"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errorPageStyle is the inline stylesheet shared by the built-in error pages.
+const errorPageStyle = `body{font-family:system-ui,sans-serif;margin:0;padding:2rem;color:#222;background:#fafafa}` +
+	`h1{margin-top:0}pre{background:#fff;border:1px solid #ddd;padding:1rem;overflow:auto}` +
+	`.line{display:block}.line.hl{background:#fde2e2}.meta{color:#777}`
+
+// ErrorPage returns a minimal HTML page for the given status and message.
+func ErrorPage(status int, message string) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		title := fmt.Sprintf("%d %s", status, http.StatusText(status))
+		if message == "" {
+			message = http.StatusText(status)
+		}
+
+		_, err := fmt.Fprintf(w,
+			`<!DOCTYPE html><html><head><meta charset="utf-8"><title>%s</title><style>%s</style></head>`+
+				`<body><h1>%s</h1><p>%s</p></body></html>`,
+			templ.EscapeString(title), errorPageStyle, templ.EscapeString(title), templ.EscapeString(message))
+		return err
+	})
+}
+
+// SourceLine is a single line of a source snippet.
+type SourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// Frame is a single stack frame of a debug page.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	// Source is the snippet around Line, empty if the file is not readable
+	Source []SourceLine
+}
+
+// DebugInfo holds everything shown on the dev mode debug page.
+type DebugInfo struct {
+	Status    int
+	Message   string
+	RequestID string
+	Method    string
+	URL       string
+	Frames    []Frame
+	Stack     string
+}
+
+// DebugPage returns a detailed HTML page with the stack trace and source snippet of an error.
+// It must only be served in dev mode.
+func DebugPage(info DebugInfo) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		var b strings.Builder
+		title := fmt.Sprintf("%d %s", info.Status, http.StatusText(info.Status))
+
+		fmt.Fprintf(&b, `<!DOCTYPE html><html><head><meta charset="utf-8"><title>%s</title><style>%s</style></head><body>`,
+			templ.EscapeString(title), errorPageStyle)
+		fmt.Fprintf(&b, `<h1>%s</h1><p><strong>%s</strong></p>`, templ.EscapeString(title), templ.EscapeString(info.Message))
+		fmt.Fprintf(&b, `<p class="meta">%s %s`, templ.EscapeString(info.Method), templ.EscapeString(info.URL))
+		if info.RequestID != "" {
+			fmt.Fprintf(&b, ` &middot; request id %s`, templ.EscapeString(info.RequestID))
+		}
+		b.WriteString(`</p>`)
+
+		for _, f := range info.Frames {
+			fmt.Fprintf(&b, `<h3>%s</h3><p class="meta">%s:%d</p>`,
+				templ.EscapeString(f.Function), templ.EscapeString(f.File), f.Line)
+			if len(f.Source) == 0 {
+				continue
+			}
+			b.WriteString(`<pre>`)
+			for _, l := range f.Source {
+				class := "line"
+				if l.Current {
+					class += " hl"
+				}
+				fmt.Fprintf(&b, `<span class="%s">%4d  %s</span>`, class, l.Number, templ.EscapeString(l.Text))
+			}
+			b.WriteString(`</pre>`)
+		}
+
+		if info.Stack != "" {
+			fmt.Fprintf(&b, `<h2>Stack</h2><pre>%s</pre>`, templ.EscapeString(info.Stack))
+		}
+		b.WriteString(`</body></html>`)
+
+		_, err := io.WriteString(w, b.String())
+		return err
+	})
+}
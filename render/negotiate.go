@@ -0,0 +1,118 @@
+// Package render provides low-level helpers for writing HTTP responses.
+// It is shared by the muxo package and its middleware.
+package render
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is a single media range of an Accept header.
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept parses an Accept header into media ranges.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		ranges = append(ranges, acceptRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	return ranges
+}
+
+// specificity ranks type/subtype above type/* above */*.
+func specificity(a acceptRange) int {
+	switch {
+	case a.typ == "*":
+		return 0
+	case a.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// matches reports whether the media range accepts the media type.
+func (a acceptRange) matches(mediaType string) bool {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	if a.typ != "*" && a.typ != typ {
+		return false
+	}
+	if a.subtype == "*" || a.subtype == subtype {
+		return true
+	}
+	// application/*+json style suffixes accept the base type, e.g. application/problem+json
+	if _, suffix, ok := strings.Cut(subtype, "+"); ok && a.subtype == suffix {
+		return true
+	}
+	return false
+}
+
+// Negotiate returns the offer that best matches the Accept header.
+// Offers are media types in server preference order. An empty header accepts the first offer.
+// It returns false if no offer is acceptable.
+func Negotiate(accept string, offers ...string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+
+	ranges := parseAccept(accept)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		// the most specific matching range decides the quality of an offer
+		q, spec := 0.0, -1
+		for _, a := range ranges {
+			if a.matches(offer) && specificity(a) > spec {
+				q, spec = a.q, specificity(a)
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	if bestQ <= 0 {
+		return "", false
+	}
+	return best, true
+}
+
+// PrefersJSON reports whether the client prefers a JSON response over HTML.
+// Without an explicit Accept header, requests with a JSON body are answered with JSON.
+func PrefersJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" || accept == "*/*" {
+		return strings.Contains(r.Header.Get("Content-Type"), "json")
+	}
+
+	offer, ok := Negotiate(accept, "text/html", "application/json", ProblemContentType)
+	return ok && offer != "text/html"
+}
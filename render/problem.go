@@ -0,0 +1,142 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details documents.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details document.
+type Problem struct {
+	// Type is a URI reference identifying the problem type, defaults to "about:blank"
+	Type string
+	// Title is a short summary of the problem type
+	Title string
+	// Status is the HTTP status code
+	Status int
+	// Detail is an explanation specific to this occurrence of the problem
+	Detail string
+	// Instance is a URI reference identifying this occurrence of the problem
+	Instance string
+	// Extensions holds additional members, they are written next to the standard members
+	Extensions map[string]any
+}
+
+// NewProblem creates a Problem for the given status with the status text as title.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Status: status,
+		Title:  http.StatusText(status),
+		Detail: detail,
+	}
+}
+
+// With sets an extension member and returns p.
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// Clone returns a copy of p with its own Extensions map, so per-request members can be
+// added to shared problems. Extension values are not copied.
+func (p *Problem) Clone() *Problem {
+	c := *p
+	c.Extensions = maps.Clone(p.Extensions)
+	return &c
+}
+
+// Error implements the error interface.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", p.Status, p.Title, p.Detail)
+	}
+	return fmt.Sprintf("%d %s", p.Status, p.Title)
+}
+
+// MarshalJSON flattens the extensions into the document.
+// Extensions never override the standard members.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+
+	typ := p.Type
+	if typ == "" {
+		typ = "about:blank"
+	}
+	m["type"] = typ
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON reads the standard members and collects all others as extensions.
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	fields := map[string]any{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.Status,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+	}
+
+	for k, raw := range m {
+		if dst, ok := fields[k]; ok {
+			if err := json.Unmarshal(raw, dst); err != nil {
+				return fmt.Errorf("problem member %s: %w", k, err)
+			}
+			continue
+		}
+
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]any)
+		}
+		p.Extensions[k] = v
+	}
+
+	return nil
+}
+
+// ProblemJSON writes p as an application/problem+json response.
+// The response status is taken from p, defaulting to 500.
+func ProblemJSON(w http.ResponseWriter, p *Problem) error {
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		return fmt.Errorf("encode problem: %w", err)
+	}
+	return nil
+}
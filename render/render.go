@@ -0,0 +1,28 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/a-h/templ"
+	"net/http"
+)
+
+// JSON writes v as a JSON response with the given status.
+func JSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return nil
+}
+
+// HTML renders the component as an HTML response with the given status.
+func HTML(w http.ResponseWriter, r *http.Request, status int, c templ.Component) error {
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.WriteHeader(status)
+	if err := c.Render(r.Context(), w); err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package muxo
+
+import (
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/up1io/muxo/codec"
+	"github.com/up1io/muxo/render"
+	"net/http"
+	"strings"
+)
+
+// Respond writes v in the format that best matches the Accept header of the request.
+// templ components are rendered as HTML like with EncodeRenderStatus, wrapped in the layout
+// of the request, any other value is encoded by a response codec of codec.Default. If the client accepts none of them, nothing is written and a 406
+// HTTPError is returned.
+func Respond(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	accept := r.Header.Get("Accept")
+	w.Header().Add("Vary", "Accept")
+
+	if c, ok := v.(templ.Component); ok {
+		if _, ok := render.Negotiate(accept, "text/html"); !ok {
+			return notAcceptable([]string{"text/html"})
+		}
+		return EncodeRenderStatus(w, r, status, c)
+	}
+
+	c, err := codec.Default.Negotiate(accept)
+	if err != nil {
+		return notAcceptable(codec.Default.ContentTypes())
+	}
+
+	w.Header().Set("Content-Type", c.ContentType())
+	w.WriteHeader(status)
+	if err := c.Encode(w, v); err != nil {
+		return fmt.Errorf("encode %s: %w", c.ContentType(), err)
+	}
+	return nil
+}
+
+// notAcceptable returns the 406 HTTPError listing the available media types.
+func notAcceptable(available []string) *HTTPError {
+	return NewHTTPError(http.StatusNotAcceptable,
+		fmt.Sprintf("Available content types: %s", strings.Join(available, ", ")), codec.ErrNotAcceptable)
+}
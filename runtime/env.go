@@ -0,0 +1,14 @@
+package runtime
+
+import "os"
+
+// EnvKey is the environment variable that selects the application environment.
+const EnvKey = "MUXO_ENV"
+
+// EnvDev is the EnvKey value set by `muxo dev`.
+const EnvDev = "dev"
+
+// IsDev reports whether the application runs in dev mode.
+func IsDev() bool {
+	return os.Getenv(EnvKey) == EnvDev
+}
@@ -3,11 +3,16 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 )
 
+// ShutdownTimeout is the time DefaultRuntime waits for open connections when shutting down.
+const ShutdownTimeout = 5 * time.Second
+
 // Runtime defines the interface for server runtimes.
 type Runtime interface {
 	// Serve starts the HTTP server with the given handler and handles graceful shutdown.
@@ -27,12 +32,39 @@ func NewDefaultRuntime(addr string) *DefaultRuntime {
 }
 
 // Serve starts an HTTP server on the configured address with the given handler.
+// When ctx is done the server stops accepting connections and waits up to
+// ShutdownTimeout for open requests to finish.
 func (r *DefaultRuntime) Serve(ctx context.Context, handler http.Handler) error {
 	addr := r.addr
 	if !strings.Contains(addr, ":") {
 		addr = ":" + addr
 	}
 
-	fmt.Printf("Starting server on %s\n", addr)
-	return http.ListenAndServe(addr, handler)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Printf("Starting server on %s\n", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown server: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,111 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxCookieSize is the largest cookie value browsers reliably accept.
+const maxCookieSize = 4000
+
+// ErrCookieTooLarge is returned when an encoded session does not fit into a cookie.
+var ErrCookieTooLarge = errors.New("session: cookie too large")
+
+// CookieStore keeps the whole session in the cookie, encrypted and authenticated with
+// AES-GCM. It needs no server-side storage, but sessions are limited to about 4KB and a
+// destroyed session can't be revoked before it expires.
+type CookieStore struct {
+	aeads []cipher.AEAD
+}
+
+// NewCookieStore creates a CookieStore. The first key encrypts new cookies, all keys are
+// tried when decrypting, so keys can be rotated by prepending a new one. Keys should
+// contain at least 32 random bytes.
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session: cookie store needs at least one key")
+	}
+
+	s := &CookieStore{}
+	for _, key := range keys {
+		// derive a key of valid AES length from keys of any length
+		sum := sha256.Sum256(key)
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return nil, fmt.Errorf("session: cookie store: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("session: cookie store: %w", err)
+		}
+		s.aeads = append(s.aeads, aead)
+	}
+
+	return s, nil
+}
+
+// Load implements Store. token is the encrypted cookie value.
+func (s *CookieStore) Load(ctx context.Context, token string) ([]byte, bool, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	for _, aead := range s.aeads {
+		if len(sealed) < aead.NonceSize() {
+			return nil, false, nil
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+		plain, err := aead.Open(nil, nonce, ciphertext, nil)
+		if err != nil {
+			continue
+		}
+		if len(plain) < 8 {
+			return nil, false, nil
+		}
+
+		expiry := time.Unix(int64(binary.BigEndian.Uint64(plain[:8])), 0)
+		if time.Now().After(expiry) {
+			return nil, false, nil
+		}
+		return plain[8:], true, nil
+	}
+
+	// tampered or encrypted with an unknown key
+	return nil, false, nil
+}
+
+// Save implements Store. It ignores token and returns the encrypted data as new token.
+func (s *CookieStore) Save(ctx context.Context, token string, data []byte, expiry time.Time) (string, error) {
+	aead := s.aeads[0]
+
+	plain := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(plain, uint64(expiry.Unix()))
+	plain = append(plain, data...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("session: nonce: %w", err)
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil))
+	if len(value) > maxCookieSize {
+		return "", ErrCookieTooLarge
+	}
+	return value, nil
+}
+
+// Delete implements Store. Cookie sessions are removed by expiring the cookie, which the
+// Manager does, so there is nothing to delete.
+func (s *CookieStore) Delete(ctx context.Context, token string) error {
+	return nil
+}
@@ -0,0 +1,113 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStore keeps each session in a file of a directory. It survives restarts but is only
+// shared between instances on the same host or on a shared volume.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("session: file store: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the file of token. The token is hashed, so it can't escape the directory
+// and the file names don't reveal valid tokens.
+func (s *FileStore) path(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".session")
+}
+
+// Load implements Store.
+func (s *FileStore) Load(ctx context.Context, token string) ([]byte, bool, error) {
+	b, err := os.ReadFile(s.path(token))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("session: file store: %w", err)
+	}
+	if len(b) < 8 {
+		return nil, false, nil
+	}
+
+	expiry := time.Unix(int64(binary.BigEndian.Uint64(b[:8])), 0)
+	if time.Now().After(expiry) {
+		_ = s.Delete(ctx, token)
+		return nil, false, nil
+	}
+	return b[8:], true, nil
+}
+
+// Save implements Store. The file is replaced atomically.
+func (s *FileStore) Save(ctx context.Context, token string, data []byte, expiry time.Time) (string, error) {
+	b := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(b, uint64(expiry.Unix()))
+	b = append(b, data...)
+
+	f, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("session: file store: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return "", fmt.Errorf("session: file store: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("session: file store: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path(token)); err != nil {
+		return "", fmt.Errorf("session: file store: %w", err)
+	}
+
+	return token, nil
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(ctx context.Context, token string) error {
+	if err := os.Remove(s.path(token)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("session: file store: %w", err)
+	}
+	return nil
+}
+
+// Cleanup removes the files of expired sessions. Call it periodically.
+func (s *FileStore) Cleanup() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.session"))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		var header [8]byte
+		_, err = f.Read(header[:])
+		f.Close()
+
+		if err != nil || now.After(time.Unix(int64(binary.BigEndian.Uint64(header[:])), 0)) {
+			_ = os.Remove(name)
+		}
+	}
+	return nil
+}
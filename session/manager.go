@@ -0,0 +1,288 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/up1io/muxo/logger"
+	"github.com/up1io/muxo/middleware"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default timeouts of a Manager.
+const (
+	DefaultIdleTimeout = 2 * time.Hour
+	DefaultLifetime    = 24 * time.Hour
+)
+
+// Manager loads and saves sessions of requests using a Store.
+type Manager struct {
+	store    Store
+	cookie   http.Cookie
+	idle     time.Duration
+	lifetime time.Duration
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithCookieName sets the name of the session cookie, "session" by default.
+func WithCookieName(name string) Option {
+	return func(m *Manager) {
+		m.cookie.Name = name
+	}
+}
+
+// WithCookie sets the path, domain, Secure and SameSite attributes of the session cookie.
+// The cookie is always HttpOnly.
+func WithCookie(path, domain string, secure bool, sameSite http.SameSite) Option {
+	return func(m *Manager) {
+		m.cookie.Path = path
+		m.cookie.Domain = domain
+		m.cookie.Secure = secure
+		m.cookie.SameSite = sameSite
+	}
+}
+
+// WithIdleTimeout sets how long a session stays valid without requests. Zero disables it.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.idle = d
+	}
+}
+
+// WithLifetime sets the absolute time after which a session expires regardless of activity.
+// Zero disables it.
+func WithLifetime(d time.Duration) Option {
+	return func(m *Manager) {
+		m.lifetime = d
+	}
+}
+
+// New creates a Manager that keeps sessions in store.
+func New(store Store, opts ...Option) *Manager {
+	m := &Manager{
+		store: store,
+		cookie: http.Cookie{
+			Name:     "session",
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		idle:     DefaultIdleTimeout,
+		lifetime: DefaultLifetime,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Middleware creates a middleware that loads the session of each request into its context
+// and saves it right before the response header is written.
+func (m *Manager) Middleware() middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := m.load(r)
+
+			sw := &sessionWriter{ResponseWriter: w, m: m, r: r, s: s}
+			next.ServeHTTP(sw, r.WithContext(NewContext(r.Context(), s)))
+			sw.commit()
+		})
+	}
+}
+
+// load returns the session of r, or a new session if it has none or it expired.
+func (m *Manager) load(r *http.Request) *Session {
+	now := time.Now()
+
+	c, err := r.Cookie(m.cookie.Name)
+	if err != nil || c.Value == "" {
+		return newSession(now)
+	}
+
+	data, found, err := m.store.Load(r.Context(), c.Value)
+	if err != nil {
+		logger.FromContext(r.Context()).Warn("session: load: %v", err)
+		return newSession(now)
+	}
+	if !found {
+		return newSession(now)
+	}
+
+	s := &Session{token: c.Value}
+	if err := json.Unmarshal(data, &s.rec); err != nil {
+		logger.FromContext(r.Context()).Warn("session: decode: %v", err)
+		return newSession(now)
+	}
+	if s.rec.Values == nil {
+		s.rec.Values = make(map[string]json.RawMessage)
+	}
+
+	if m.expired(s, now) {
+		_ = m.store.Delete(r.Context(), c.Value)
+		return newSession(now)
+	}
+
+	s.rec.LastAccess = now
+	return s
+}
+
+// expired reports whether s reached its idle or absolute timeout.
+func (m *Manager) expired(s *Session, now time.Time) bool {
+	if m.idle > 0 && now.Sub(s.rec.LastAccess) > m.idle {
+		return true
+	}
+	return m.lifetime > 0 && now.Sub(s.rec.CreatedAt) > m.lifetime
+}
+
+// expiry returns when s expires if no further request arrives.
+func (m *Manager) expiry(s *Session) time.Time {
+	var expiry time.Time
+	if m.lifetime > 0 {
+		expiry = s.rec.CreatedAt.Add(m.lifetime)
+	}
+	if m.idle > 0 {
+		if idle := s.rec.LastAccess.Add(m.idle); expiry.IsZero() || idle.Before(expiry) {
+			expiry = idle
+		}
+	}
+	if expiry.IsZero() {
+		// without timeouts the store still needs an expiry, the cookie lives for the browser session
+		expiry = s.rec.LastAccess.Add(DefaultLifetime)
+	}
+	return expiry
+}
+
+// save persists s and writes its cookie to w. Sessions that were never modified and have no
+// token yet are not saved, so anonymous visitors do not create sessions.
+func (m *Manager) save(ctx context.Context, w http.ResponseWriter, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.destroyed {
+		if s.token != "" {
+			if err := m.store.Delete(ctx, s.token); err != nil {
+				return err
+			}
+		}
+		if s.oldToken != "" {
+			_ = m.store.Delete(ctx, s.oldToken)
+		}
+		m.writeCookie(w, "", time.Unix(0, 0))
+		return nil
+	}
+
+	if s.token == "" && !s.modified {
+		return nil
+	}
+	// without an idle timeout, unmodified sessions don't need to be touched
+	if !s.modified && m.idle == 0 {
+		return nil
+	}
+
+	if s.oldToken != "" {
+		if err := m.store.Delete(ctx, s.oldToken); err != nil {
+			return err
+		}
+		s.oldToken = ""
+	}
+
+	token := s.token
+	if token == "" {
+		var err error
+		if token, err = newToken(); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(s.rec)
+	if err != nil {
+		return fmt.Errorf("session: encode: %w", err)
+	}
+
+	expiry := m.expiry(s)
+	token, err = m.store.Save(ctx, token, data, expiry)
+	if err != nil {
+		return fmt.Errorf("session: save: %w", err)
+	}
+	s.token = token
+
+	if m.lifetime == 0 && m.idle == 0 {
+		expiry = time.Time{}
+	}
+	m.writeCookie(w, token, expiry)
+	return nil
+}
+
+// writeCookie sets the session cookie to value. A zero expiry creates a browser session cookie.
+func (m *Manager) writeCookie(w http.ResponseWriter, value string, expiry time.Time) {
+	c := m.cookie
+	c.Value = value
+	c.Expires = expiry
+	if value == "" {
+		c.MaxAge = -1
+	}
+	w.Header().Add("Set-Cookie", c.String())
+	w.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
+}
+
+// newToken returns a random session token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("session: token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sessionWriter saves the session before the header of the response is written,
+// since the cookie can't be set afterwards.
+type sessionWriter struct {
+	http.ResponseWriter
+	m    *Manager
+	r    *http.Request
+	s    *Session
+	once sync.Once
+}
+
+// commit saves the session once.
+func (w *sessionWriter) commit() {
+	w.once.Do(func() {
+		if err := w.m.save(w.r.Context(), w.ResponseWriter, w.s); err != nil {
+			logger.FromContext(w.r.Context()).Error("%v", err)
+		}
+	})
+}
+
+func (w *sessionWriter) WriteHeader(status int) {
+	w.commit()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush saves the session and flushes the underlying writer.
+func (w *sessionWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is the Flush used by http.ResponseController, it reports errors.
+func (w *sessionWriter) FlushError() error {
+	w.commit()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
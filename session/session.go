@@ -0,0 +1,208 @@
+// Package session provides per-request sessions backed by pluggable stores.
+//
+// A Manager loads the session of a request in its middleware and saves it before the
+// response header is written. Handlers and templ components read and write session
+// values through the request context:
+//
+//	session.Set(ctx, "user_id", 42)
+//	id, ok := session.Get[int](ctx, "user_id")
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoSession is returned when the context does not carry a session,
+// usually because the session middleware is not installed.
+var ErrNoSession = errors.New("session: no session in context")
+
+// Flash is a one-time message shown on the next page, e.g. after a redirect.
+type Flash struct {
+	// Kind classifies the message, e.g. "info", "success" or "error"
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// record is the persisted form of a session.
+type record struct {
+	Values     map[string]json.RawMessage `json:"values,omitempty"`
+	Flashes    []Flash                    `json:"flashes,omitempty"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	LastAccess time.Time                  `json:"last_access"`
+}
+
+// Session is the session of a single request.
+type Session struct {
+	mu        sync.Mutex
+	token     string
+	rec       record
+	modified  bool
+	renewed   bool
+	destroyed bool
+	// oldToken is the token replaced by Renew, it is deleted from the store on save
+	oldToken string
+}
+
+// newSession creates an empty session.
+func newSession(now time.Time) *Session {
+	return &Session{
+		rec: record{
+			Values:     make(map[string]json.RawMessage),
+			CreatedAt:  now,
+			LastAccess: now,
+		},
+	}
+}
+
+type key int
+
+var sessionKey key
+
+// NewContext returns a new Context that carries the session s.
+func NewContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey, s)
+}
+
+// FromContext returns the session stored in ctx, if any.
+func FromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(sessionKey).(*Session)
+	return s, ok
+}
+
+// Active reports whether ctx carries a session that was loaded from the store or holds
+// data, so the response may depend on who sent the request.
+func Active(ctx context.Context) bool {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token != "" || s.oldToken != "" || s.modified || s.destroyed
+}
+
+// Get returns the value stored under key, decoded into T.
+// It returns false if there is no session, no value or the value is not a T.
+func Get[T any](ctx context.Context, key string) (T, bool) {
+	var v T
+	s, ok := FromContext(ctx)
+	if !ok {
+		return v, false
+	}
+
+	s.mu.Lock()
+	raw, ok := s.rec.Values[key]
+	s.mu.Unlock()
+	if !ok {
+		return v, false
+	}
+
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// Set stores v under key. v must be JSON serializable.
+func Set(ctx context.Context, key string, v any) error {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoSession
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("session: encode %s: %w", key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rec.Values[key] = raw
+	s.modified = true
+	return nil
+}
+
+// Delete removes the value stored under key.
+func Delete(ctx context.Context, key string) {
+	if s, ok := FromContext(ctx); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.rec.Values[key]; ok {
+			delete(s.rec.Values, key)
+			s.modified = true
+		}
+	}
+}
+
+// Renew issues a new session token while keeping the session data.
+// Call it whenever the privilege level changes, e.g. on login, to prevent session fixation.
+func Renew(ctx context.Context) error {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoSession
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.renewed {
+		s.oldToken = s.token
+	}
+	s.token = ""
+	s.renewed = true
+	s.modified = true
+	return nil
+}
+
+// Destroy removes the session from the store and expires its cookie, e.g. on logout.
+func Destroy(ctx context.Context) error {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoSession
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+	s.rec.Values = make(map[string]json.RawMessage)
+	s.rec.Flashes = nil
+	return nil
+}
+
+// AddFlash adds a flash message that is kept until it is read with Flashes.
+func AddFlash(ctx context.Context, kind, message string) error {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return ErrNoSession
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rec.Flashes = append(s.rec.Flashes, Flash{Kind: kind, Message: message})
+	s.modified = true
+	return nil
+}
+
+// Flashes returns and removes all flash messages. It can be called from templ components
+// rendered with EncodeRender, which renders before writing the header. In streamed pages
+// the removal is only saved if Flashes is called before the first flush.
+func Flashes(ctx context.Context) []Flash {
+	s, ok := FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes := s.rec.Flashes
+	if len(flashes) > 0 {
+		s.rec.Flashes = nil
+		s.modified = true
+	}
+	return flashes
+}
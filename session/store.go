@@ -0,0 +1,111 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store persists encoded sessions. Implement it to keep sessions in Redis, SQL databases
+// or other shared storage.
+type Store interface {
+	// Load returns the data saved for token.
+	// It returns false if the token is unknown or expired.
+	Load(ctx context.Context, token string) (data []byte, found bool, err error)
+	// Save stores data for token until expiry and returns the token to send to the client.
+	// Server-side stores return token unchanged, the CookieStore returns the encrypted data.
+	Save(ctx context.Context, token string, data []byte, expiry time.Time) (string, error)
+	// Delete removes the data saved for token.
+	Delete(ctx context.Context, token string) error
+}
+
+// memoryEntry is a session kept by the MemoryStore.
+type memoryEntry struct {
+	data   []byte
+	expiry time.Time
+}
+
+// MemoryStore keeps sessions in process memory. Sessions are lost on restart and not
+// shared between instances, which makes it suitable for development and single instances.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryStore creates a MemoryStore that evicts expired sessions every cleanupInterval.
+// A zero interval disables the background cleanup, expired sessions are then only
+// removed when they are loaded.
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		stop:    make(chan struct{}),
+	}
+
+	if cleanupInterval > 0 {
+		go s.cleanup(cleanupInterval)
+	}
+
+	return s
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(ctx context.Context, token string) ([]byte, bool, error) {
+	s.mu.RLock()
+	e, ok := s.entries[token]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(e.expiry) {
+		_ = s.Delete(ctx, token)
+		return nil, false, nil
+	}
+	return e.data, true, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(ctx context.Context, token string, data []byte, expiry time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[token] = memoryEntry{data: data, expiry: expiry}
+	return token, nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, token)
+	return nil
+}
+
+// Close stops the background cleanup.
+func (s *MemoryStore) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// cleanup removes expired sessions every interval until Close is called.
+func (s *MemoryStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for token, e := range s.entries {
+				if now.After(e.expiry) {
+					delete(s.entries, token)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package muxo
+
+import "context"
+
+type shutdownKey struct{}
+
+// NewShutdownContext returns a new Context that carries the shutdown signal of the App.
+func NewShutdownContext(ctx context.Context, done <-chan struct{}) context.Context {
+	return context.WithValue(ctx, shutdownKey{}, done)
+}
+
+// ShutdownSignal returns a channel that is closed when the App serving the request
+// shuts down. Outside of an App it returns nil, which blocks forever in a select.
+func ShutdownSignal(ctx context.Context) <-chan struct{} {
+	done, _ := ctx.Value(shutdownKey{}).(<-chan struct{})
+	return done
+}
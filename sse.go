@@ -0,0 +1,190 @@
+package muxo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a single Server-Sent Event.
+type Event struct {
+	// ID sets the last event id the client sends back when reconnecting
+	ID string
+	// Event is the event type, empty for the default "message" type
+	Event string
+	// Data is written as is when it is a string or []byte and as JSON otherwise
+	Data any
+	// Retry tells the client how long to wait before reconnecting, zero omits it
+	Retry time.Duration
+}
+
+// SSEWriter writes Server-Sent Events (text/event-stream) to a response.
+// It is safe for concurrent use.
+type SSEWriter struct {
+	mu   sync.Mutex
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	done chan struct{}
+	stop chan struct{}
+	once sync.Once
+	// wg tracks the keep-alive goroutine
+	wg sync.WaitGroup
+}
+
+// NewSSEWriter writes the header of an event stream and returns a writer for its events.
+// The stream ends when the client disconnects, the App shuts down or Close is called,
+// Done reports all three. The handler must call Close before it returns, usually with
+// defer, since the response must not be written once the handler has finished:
+//
+//	s, err := muxo.NewSSEWriter(w, r)
+//	if err != nil {
+//		return err
+//	}
+//	defer s.Close()
+func NewSSEWriter(w http.ResponseWriter, r *http.Request) (*SSEWriter, error) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return nil, fmt.Errorf("sse: %w", err)
+	}
+
+	s := &SSEWriter{
+		w:    w,
+		rc:   rc,
+		done: make(chan struct{}),
+		stop: make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-r.Context().Done():
+		case <-ShutdownSignal(r.Context()):
+		case <-s.stop:
+		}
+		close(s.done)
+	}()
+
+	return s, nil
+}
+
+// Done returns a channel that is closed when the stream has ended.
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send writes e and flushes it. It returns ErrStreamClosed once the stream has ended.
+func (s *SSEWriter) Send(e Event) error {
+	data, err := eventData(e.Data)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", sanitizeField(e.ID))
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", sanitizeField(e.Event))
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Comment writes a comment line, which clients ignore. It is used to keep connections alive.
+func (s *SSEWriter) Comment(text string) error {
+	return s.write(": " + sanitizeField(text) + "\n\n")
+}
+
+// KeepAlive writes a comment every interval until the stream ends,
+// so proxies do not close idle connections. Close waits for it to stop.
+func (s *SSEWriter) KeepAlive(interval time.Duration) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if err := s.Comment("keep-alive"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close ends the stream and waits for KeepAlive and writes in progress, so nothing is
+// written to the response afterwards. It does not close the underlying connection,
+// the handler finishes the response by returning.
+func (s *SSEWriter) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	s.wg.Wait()
+
+	// a write holding the lock has passed the done check, let it finish
+	s.mu.Lock()
+	s.mu.Unlock()
+}
+
+// write writes raw event stream data and flushes it.
+func (s *SSEWriter) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return ErrStreamClosed
+	default:
+	}
+
+	if _, err := io.WriteString(s.w, data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// eventData formats the data of an event.
+func eventData(v any) (string, error) {
+	switch d := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return d, nil
+	case []byte:
+		return string(d), nil
+	default:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return "", fmt.Errorf("encode event data: %w", err)
+		}
+		return string(b), nil
+	}
+}
+
+// sanitizeField removes line breaks, which would end a field early.
+func sanitizeField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,103 @@
+package muxo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+)
+
+// ErrStreamClosed is returned by streaming helpers when the client disconnected
+// or the App shut down before the stream ended.
+var ErrStreamClosed = errors.New("stream closed")
+
+// EncodeStream writes the values of seq as newline delimited JSON (application/x-ndjson).
+// Every value is flushed immediately. The stream stops with ErrStreamClosed when the
+// client disconnects or the App shuts down; the check happens between values.
+func EncodeStream[T any](w http.ResponseWriter, r *http.Request, status int, seq iter.Seq[T]) error {
+	s, err := newNDJSONStream(w, r, status)
+	if err != nil {
+		return err
+	}
+
+	for v := range seq {
+		if err := s.closed(); err != nil {
+			return err
+		}
+		if err := s.write(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeStreamChan writes the values received from ch as newline delimited JSON until
+// ch is closed. Unlike EncodeStream it stops while waiting for the next value when the
+// client disconnects or the App shuts down.
+func EncodeStreamChan[T any](w http.ResponseWriter, r *http.Request, status int, ch <-chan T) error {
+	s, err := newNDJSONStream(w, r, status)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return ErrStreamClosed
+		case <-ShutdownSignal(r.Context()):
+			return ErrStreamClosed
+		case v, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := s.write(v); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ndjsonStream writes flushed JSON lines.
+type ndjsonStream struct {
+	ctx context.Context
+	rc  *http.ResponseController
+	w   http.ResponseWriter
+	enc *json.Encoder
+}
+
+// newNDJSONStream writes the header of an NDJSON response.
+func newNDJSONStream(w http.ResponseWriter, r *http.Request, status int) (*ndjsonStream, error) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(status)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+
+	return &ndjsonStream{ctx: r.Context(), rc: rc, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// closed returns ErrStreamClosed if the client is gone or the App shuts down.
+func (s *ndjsonStream) closed() error {
+	select {
+	case <-s.ctx.Done():
+		return ErrStreamClosed
+	case <-ShutdownSignal(s.ctx):
+		return ErrStreamClosed
+	default:
+		return nil
+	}
+}
+
+// write encodes v as a single line and flushes it.
+func (s *ndjsonStream) write(v any) error {
+	if err := s.enc.Encode(v); err != nil {
+		return fmt.Errorf("encode json: %w", err)
+	}
+	return s.rc.Flush()
+}
@@ -0,0 +1,218 @@
+package muxo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/a-h/templ"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// suspenseScript swaps a streamed chunk into its placeholder.
+const suspenseScript = `function muxoSwap(id){var t=document.getElementById("muxo-c-"+id),` +
+	`p=document.getElementById("muxo-s-"+id);if(t&&p){p.replaceWith(t.content);t.remove()}}`
+
+// suspenseChunk is the result of a Suspense boundary rendered in the background.
+type suspenseChunk struct {
+	id int
+	// parent is the id of the enclosing boundary, zero at the top level
+	parent int
+	html   []byte
+	err    error
+}
+
+// stream is the state of a page rendered with EncodeRenderStream.
+type stream struct {
+	w      io.Writer
+	rc     *http.ResponseController
+	mu     sync.Mutex
+	nextID int
+	chunks chan suspenseChunk
+	wg     sync.WaitGroup
+}
+
+type streamKey struct{}
+
+// suspenseParentKey holds the id of the Suspense boundary a component renders in.
+type suspenseParentKey struct{}
+
+// streamFromContext returns the stream of the page being rendered, if it is streamed.
+func streamFromContext(ctx context.Context) (*stream, bool) {
+	s, ok := ctx.Value(streamKey{}).(*stream)
+	return s, ok
+}
+
+// flush sends everything written to w so far to the client. w is the writer a component
+// renders to, which may be a buffer templ placed in front of the response.
+func (s *stream) flush(w io.Writer) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	case http.Flusher:
+		f.Flush()
+	}
+	return s.rc.Flush()
+}
+
+// EncodeRenderStream renders v wrapped in the layout of the request, if any, and streams
+// the output instead of buffering it. Place Flush in the layout after the document head
+// to send the head and the layout shell immediately. Components inside Suspense
+// boundaries render concurrently; their fallback is sent first and their content follows
+// at the end of the document in the order the boundaries finish. Nested boundaries are
+// sent after the boundary containing them, since their placeholder is part of its content.
+//
+// Since the head is sent before the body renders, slots populated by the body are only
+// visible to parts of the layout rendered after the body. Once streaming has begun, render
+// errors can no longer change the status; they are returned after the response is cut short.
+func EncodeRenderStream(w http.ResponseWriter, r *http.Request, status int, v templ.Component) error {
+	s := &stream{
+		w:      w,
+		rc:     http.NewResponseController(w),
+		chunks: make(chan suspenseChunk),
+	}
+
+	ctx := newSlotsContext(r.Context())
+	ctx = context.WithValue(ctx, streamKey{}, s)
+
+	page := v
+	if layout, ok := LayoutFromContext(ctx); ok {
+		page = layout(v)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(status)
+
+	renderErr := page.Render(ctx, w)
+
+	// wait for the boundaries in the background, so chunks are written as they finish
+	go func() {
+		s.wg.Wait()
+		close(s.chunks)
+	}()
+
+	if err := s.writeChunks(ctx); err != nil && renderErr == nil {
+		renderErr = err
+	}
+	if renderErr != nil {
+		return fmt.Errorf("render stream: %w", renderErr)
+	}
+	return nil
+}
+
+// writeChunks writes the Suspense chunks in the order they finish. Chunks of nested
+// boundaries are held back until the chunk containing their placeholder is written.
+func (s *stream) writeChunks(ctx context.Context) error {
+	scriptWritten := false
+	nonce := templ.GetNonce(ctx)
+	written := map[int]bool{0: true}
+	pending := make(map[int][]suspenseChunk)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case chunk, ok := <-s.chunks:
+			if !ok {
+				return nil
+			}
+			if chunk.err != nil {
+				return chunk.err
+			}
+			if !written[chunk.parent] {
+				pending[chunk.parent] = append(pending[chunk.parent], chunk)
+				continue
+			}
+
+			var b bytes.Buffer
+			if !scriptWritten {
+				fmt.Fprintf(&b, `<script%s>%s</script>`, nonceAttr(nonce), suspenseScript)
+				scriptWritten = true
+			}
+			for queue := []suspenseChunk{chunk}; len(queue) > 0; queue = queue[1:] {
+				c := queue[0]
+				fmt.Fprintf(&b, `<template id="muxo-c-%d">`, c.id)
+				b.Write(c.html)
+				fmt.Fprintf(&b, `</template><script%s>muxoSwap(%d)</script>`, nonceAttr(nonce), c.id)
+
+				written[c.id] = true
+				queue = append(queue, pending[c.id]...)
+				delete(pending, c.id)
+			}
+
+			if _, err := b.WriteTo(s.w); err != nil {
+				return err
+			}
+			if err := s.flush(s.w); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// nonceAttr returns the nonce attribute for inline scripts, if a CSP nonce is set.
+func nonceAttr(nonce string) string {
+	if nonce == "" {
+		return ""
+	}
+	return fmt.Sprintf(` nonce="%s"`, templ.EscapeString(nonce))
+}
+
+// Flush sends everything rendered so far to the client when the page is rendered with
+// EncodeRenderStream. Otherwise it renders nothing.
+func Flush() templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		if s, ok := streamFromContext(ctx); ok {
+			return s.flush(w)
+		}
+		return nil
+	})
+}
+
+// Suspense renders fallback in place of c and streams c once it has rendered, when the page
+// is rendered with EncodeRenderStream. c renders concurrently with the rest of the page and
+// must therefore not depend on the render order. Otherwise c is rendered in place.
+func Suspense(fallback, c templ.Component) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		s, ok := streamFromContext(ctx)
+		if !ok {
+			return c.Render(ctx, w)
+		}
+
+		s.mu.Lock()
+		s.nextID++
+		id := s.nextID
+		s.mu.Unlock()
+		parent, _ := ctx.Value(suspenseParentKey{}).(int)
+
+		if _, err := fmt.Fprintf(w, `<div id="muxo-s-%d">`, id); err != nil {
+			return err
+		}
+		if err := fallback.Render(ctx, w); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `</div>`); err != nil {
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+
+			var b bytes.Buffer
+			err := c.Render(context.WithValue(ctx, suspenseParentKey{}, id), &b)
+
+			select {
+			case s.chunks <- suspenseChunk{id: id, parent: parent, html: b.Bytes(), err: err}:
+			case <-ctx.Done():
+			}
+		}()
+
+		// send the fallback right away instead of waiting for the next flush
+		return s.flush(w)
+	})
+}
@@ -0,0 +1,162 @@
+package muxo
+
+import (
+	"fmt"
+	"github.com/up1io/muxo/validation"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// UploadedFile is a file received in a multipart/form-data request.
+type UploadedFile struct {
+	*multipart.FileHeader
+	// ContentType is the MIME type sniffed from the file content.
+	// Unlike the Content-Type sent by the client, it cannot be spoofed by renaming the file.
+	ContentType string
+}
+
+// newUploadedFile wraps fh and sniffs its content type.
+func newUploadedFile(fh *multipart.FileHeader) (UploadedFile, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return UploadedFile{}, fmt.Errorf("open uploaded file %s: %w", fh.Filename, err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return UploadedFile{}, fmt.Errorf("read uploaded file %s: %w", fh.Filename, err)
+	}
+
+	return UploadedFile{FileHeader: fh, ContentType: http.DetectContentType(buf[:n])}, nil
+}
+
+// HasType reports whether the sniffed content type matches one of the patterns.
+// Patterns are MIME types and may end in a wildcard, e.g. "image/*".
+func (f UploadedFile) HasType(patterns ...string) bool {
+	typ, _, _ := strings.Cut(f.ContentType, ";")
+	typ = strings.TrimSpace(typ)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, typ); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the file size and content type. It returns the problem as a message id
+// with its parameters and false if the file is invalid, so it can be added to the problems
+// of a MessageValidator and is translated like the validate tag rules:
+//
+//	if m, ok := f.Avatar.Validate(1<<20, "image/*"); !ok {
+//		problems["avatar"] = m
+//	}
+//
+// A maxSize of zero skips the size check and no types skip the type check.
+func (f UploadedFile) Validate(maxSize int64, types ...string) (validation.Message, bool) {
+	if f.FileHeader == nil {
+		return validation.Msg("is required"), false
+	}
+	if maxSize > 0 && f.Size > maxSize {
+		return validation.Msg("must not be larger than %d bytes", maxSize), false
+	}
+	if len(types) > 0 && !f.HasType(types...) {
+		return validation.Msg("file type %s is not allowed", f.ContentType), false
+	}
+	return validation.Message{}, true
+}
+
+var (
+	fileHeaderType   = reflect.TypeOf((*multipart.FileHeader)(nil))
+	uploadedFileType = reflect.TypeOf(UploadedFile{})
+)
+
+// decodeFiles assigns the files of a parsed multipart form to the fields of v.
+// Fields of type *multipart.FileHeader, UploadedFile and slices of them are matched by
+// their schema tag or, case-insensitively, by their name.
+func decodeFiles(v any, files map[string][]*multipart.FileHeader, maxFileSize int64) error {
+	for name, headers := range files {
+		for _, fh := range headers {
+			if maxFileSize > 0 && fh.Size > maxFileSize {
+				return &DecodeError{
+					Status: http.StatusRequestEntityTooLarge,
+					Err:    fmt.Errorf("file %s exceeds the maximum size of %d bytes", name, maxFileSize),
+				}
+			}
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		headers := lookupFiles(files, fieldAlias(field))
+		if len(headers) == 0 {
+			continue
+		}
+
+		fv := rv.Field(i)
+		switch field.Type {
+		case fileHeaderType:
+			fv.Set(reflect.ValueOf(headers[0]))
+		case reflect.SliceOf(fileHeaderType):
+			fv.Set(reflect.ValueOf(headers))
+		case uploadedFileType:
+			f, err := newUploadedFile(headers[0])
+			if err != nil {
+				return err
+			}
+			fv.Set(reflect.ValueOf(f))
+		case reflect.SliceOf(uploadedFileType):
+			out := make([]UploadedFile, 0, len(headers))
+			for _, fh := range headers {
+				f, err := newUploadedFile(fh)
+				if err != nil {
+					return err
+				}
+				out = append(out, f)
+			}
+			fv.Set(reflect.ValueOf(out))
+		}
+	}
+
+	return nil
+}
+
+// fieldAlias returns the form key of a struct field, following the schema tag convention.
+func fieldAlias(field reflect.StructField) string {
+	if tag := field.Tag.Get("schema"); tag != "" {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// lookupFiles returns the files for key, falling back to a case-insensitive match.
+func lookupFiles(files map[string][]*multipart.FileHeader, key string) []*multipart.FileHeader {
+	if fhs, ok := files[key]; ok {
+		return fhs
+	}
+	for k, fhs := range files {
+		if strings.EqualFold(k, key) {
+			return fhs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"context"
+	"github.com/up1io/muxo/module/local"
+)
+
+// Message is a problem description as a message id with its template parameters.
+// The id is a gettext message id in fmt.Printf syntax, e.g. "must be at least %v characters long".
+type Message struct {
+	ID   string
+	Args []any
+}
+
+// Msg creates a Message.
+func Msg(id string, args ...any) Message {
+	return Message{ID: id, Args: args}
+}
+
+// Text returns the message translated for the language negotiated for the request of ctx.
+func (m Message) Text(ctx context.Context) string {
+	return local.TextContext(ctx, m.ID, m.Args...)
+}
+
+// Problems maps field names to untranslated problem messages.
+type Problems map[string]Message
+
+// Add records a problem for field.
+func (p Problems) Add(field, id string, args ...any) {
+	p[field] = Msg(id, args...)
+}
+
+// Merge copies all problems of other into p, overriding problems for the same field.
+func (p Problems) Merge(other Problems) {
+	for k, m := range other {
+		p[k] = m
+	}
+}
+
+// Localize translates every message for the language negotiated for the request of ctx.
+func (p Problems) Localize(ctx context.Context) map[string]string {
+	out := make(map[string]string, len(p))
+	for k, m := range p {
+		out[k] = m.Text(ctx)
+	}
+	return out
+}
+
+// FromStrings converts plain problem descriptions into messages without parameters.
+// The descriptions are used as message ids.
+func FromStrings(problems map[string]string) Problems {
+	out := make(Problems, len(problems))
+	for k, s := range problems {
+		out[k] = Msg(s)
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// rule is a single parsed rule of a validate tag, e.g. min=3.
+type rule struct {
+	name  string
+	param string
+	// limit is the parameter of min, max and len
+	limit float64
+	// re is the pattern of regex
+	re *regexp.Regexp
+	// options are the values of oneof
+	options []string
+}
+
+// parseRules parses a validate tag into its rules. It returns an error for unknown rules
+// and malformed parameters.
+func parseRules(tag string) ([]rule, error) {
+	var rules []rule
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(part, "=")
+		r := rule{name: name, param: param}
+
+		var err error
+		switch name {
+		case "required", "omitempty", "email", "url", "dive":
+		case "min", "max":
+			r.limit, err = strconv.ParseFloat(param, 64)
+		case "len":
+			var n int
+			n, err = strconv.Atoi(param)
+			r.limit = float64(n)
+		case "regex":
+			r.re, err = regexp.Compile(param)
+		case "oneof":
+			r.options = strings.Fields(param)
+			if len(r.options) == 0 {
+				err = errors.New("no options")
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s parameter %q: %w", name, param, err)
+		}
+
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// supports reports whether the rule can be applied to values of type t.
+func (r rule) supports(t reflect.Type) bool {
+	switch r.name {
+	case "min", "max":
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+		return false
+	case "len":
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// check applies the rule to fv. On failure it returns the message id and its arguments.
+// The rule must support the type of fv, see supports.
+func (r rule) check(fv reflect.Value) (msg string, args []any, ok bool) {
+	if r.name == "required" {
+		return "is required", nil, !isEmpty(fv)
+	}
+
+	v := indirect(fv)
+	if !v.IsValid() {
+		// nil pointers only fail required
+		return "", nil, true
+	}
+
+	switch r.name {
+	case "min":
+		return checkBound(v, r, func(n, limit float64) bool { return n >= limit })
+	case "max":
+		return checkBound(v, r, func(n, limit float64) bool { return n <= limit })
+	case "len":
+		want := int(r.limit)
+		if v.Kind() == reflect.String {
+			return "must be exactly %d characters long", []any{want}, utf8.RuneCountInString(v.String()) == want
+		}
+		return "must contain exactly %d items", []any{want}, v.Len() == want
+	case "regex":
+		return "has an invalid format", nil, v.Kind() != reflect.String || r.re.MatchString(v.String())
+	case "email":
+		return "must be a valid email address", nil, v.Kind() != reflect.String || isEmail(v.String())
+	case "url":
+		return "must be a valid URL", nil, v.Kind() != reflect.String || isURL(v.String())
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, o := range r.options {
+			if s == o {
+				return "", nil, true
+			}
+		}
+		return "must be one of %s", []any{strings.Join(r.options, ", ")}, false
+	}
+
+	return "", nil, true
+}
+
+// checkBound implements min and max for strings, collections and numbers.
+func checkBound(v reflect.Value, r rule, cmp func(n, limit float64) bool) (string, []any, bool) {
+	limit := r.limit
+	least := r.name == "min"
+	switch v.Kind() {
+	case reflect.String:
+		msg := "must be at most %v characters long"
+		if least {
+			msg = "must be at least %v characters long"
+		}
+		return msg, []any{limit}, cmp(float64(utf8.RuneCountInString(v.String())), limit)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		msg := "must contain at most %v items"
+		if least {
+			msg = "must contain at least %v items"
+		}
+		return msg, []any{limit}, cmp(float64(v.Len()), limit)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return boundMessage(least), []any{limit}, cmp(float64(v.Int()), limit)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return boundMessage(least), []any{limit}, cmp(float64(v.Uint()), limit)
+	default:
+		return boundMessage(least), []any{limit}, cmp(v.Float(), limit)
+	}
+}
+
+// boundMessage returns the message id for numeric min and max violations.
+func boundMessage(least bool) string {
+	if least {
+		return "must be at least %v"
+	}
+	return "must be at most %v"
+}
+
+// isEmpty reports whether fv is missing for the purpose of the required rule.
+func isEmpty(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return fv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return fv.IsNil()
+	default:
+		return fv.IsZero()
+	}
+}
+
+// isEmail reports whether s is a plain email address without display name.
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// isURL reports whether s is an absolute URL with scheme and host.
+func isURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
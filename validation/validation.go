@@ -0,0 +1,320 @@
+// Package validation provides a struct tag driven rule engine.
+//
+// Rules are declared in the validate tag and separated by commas:
+//
+//	type Signup struct {
+//		Name  string   `json:"name" validate:"required,min=2,max=50"`
+//		Email string   `json:"email" validate:"required,email"`
+//		Role  string   `json:"role" validate:"oneof=admin editor viewer"`
+//		Tags  []string `json:"tags" validate:"max=5,dive,min=1"`
+//	}
+//
+// Supported rules are required, omitempty, min, max, len, regex, email, url, oneof and dive.
+// Regex patterns cannot contain commas, use \x2C instead. Unknown rules, malformed
+// parameters and rules not fitting the field type make Check return an error; Verify
+// reports them at startup.
+// Nested structs are validated recursively. Rules after dive apply to the elements
+// of a slice, array or map. Problems are keyed by the json, schema or field name,
+// joined with dots for nested fields, and translated through the request's locale.
+package validation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TagName is the struct tag read by the rule engine.
+const TagName = "validate"
+
+// Validate checks v against the rules of its validate tags and returns the problems
+// translated for the language negotiated for the request of ctx.
+// If len(problems) == 0 then v is valid. Values that are not structs are always valid.
+// It returns an error if a validate tag is malformed, see Verify.
+func Validate(ctx context.Context, v any) (map[string]string, error) {
+	problems, err := Check(v)
+	if err != nil {
+		return nil, err
+	}
+	return problems.Localize(ctx), nil
+}
+
+// Check checks v against the rules of its validate tags and returns the untranslated problems.
+// It returns an error if a validate tag is malformed, see Verify.
+func Check(v any) (Problems, error) {
+	problems := make(Problems)
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return problems, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		if err := validateStruct(rv, "", problems); err != nil {
+			return nil, err
+		}
+	}
+
+	return problems, nil
+}
+
+// Verify checks the validate tags of the type of v and of the structs it contains for
+// unknown rules, malformed parameters and rules that do not fit the field type. Call it
+// at startup, so a typo fails there instead of on the first request validating the type.
+// v is only used for its type, e.g. Verify(Signup{}).
+func Verify(v any) error {
+	return verifyType(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+// MustVerify is like Verify for several values but panics on the first error.
+func MustVerify(vs ...any) {
+	for _, v := range vs {
+		if err := Verify(v); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// verifyType verifies the tags of struct type t and of the struct types of its fields.
+func verifyType(t reflect.Type, seen map[reflect.Type]bool) error {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || isOpaqueStruct(t) || seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	fields, err := fieldsOf(t)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := verifyType(t.Field(f.index).Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// field is a struct field to validate with the rules of its validate tag.
+type field struct {
+	index int
+	// name is the problem key of the field
+	name string
+	// embedded fields are validated as part of the parent struct
+	embedded bool
+	rules    []rule
+}
+
+// parsedStruct holds the fields of a struct type or the error parsing its tags.
+type parsedStruct struct {
+	fields []field
+	err    error
+}
+
+// structs caches the parsed validate tags by struct type.
+var structs sync.Map
+
+// fieldsOf returns the fields of struct type t to validate, parsing its tags on first use.
+func fieldsOf(t reflect.Type) ([]field, error) {
+	if p, ok := structs.Load(t); ok {
+		p := p.(parsedStruct)
+		return p.fields, p.err
+	}
+
+	fields, err := parseStruct(t)
+	structs.Store(t, parsedStruct{fields: fields, err: err})
+	return fields, err
+}
+
+// parseStruct parses the validate tags of the fields of struct type t.
+func parseStruct(t reflect.Type) ([]field, error) {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag := sf.Tag.Get(TagName)
+		if tag == "-" {
+			continue
+		}
+
+		// fields of embedded structs are promoted, so they keep the parent prefix
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, field{index: i, embedded: true})
+			continue
+		}
+
+		rules, err := parseRules(tag)
+		if err == nil {
+			err = checkTypes(sf.Type, rules)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("validation: field %s.%s: %w", t, sf.Name, err)
+		}
+		fields = append(fields, field{index: i, name: fieldName(sf), rules: rules})
+	}
+	return fields, nil
+}
+
+// checkTypes reports rules that cannot be applied to values of type t. Rules on interface
+// types are checked against the dynamic type of the value during validation.
+func checkTypes(t reflect.Type, rules []rule) error {
+	own, elem, dive := splitDive(rules)
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+
+	for _, r := range own {
+		if !r.supports(t) {
+			return fmt.Errorf("rule %q does not support %s", r.name, t)
+		}
+	}
+
+	if !dive {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return checkTypes(t.Elem(), elem)
+	}
+	return fmt.Errorf("rule \"dive\" does not support %s", t)
+}
+
+// validateStruct checks the fields of rv, prefixing problem keys with prefix.
+func validateStruct(rv reflect.Value, prefix string, problems Problems) error {
+	fields, err := fieldsOf(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		if f.embedded {
+			if err := validateStruct(fv, prefix, problems); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validateValue(fv, prefix+f.name, f.rules, problems); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue applies rules to fv and descends into nested structs and dived collections.
+func validateValue(fv reflect.Value, name string, rules []rule, problems Problems) error {
+	own, elem, dive := splitDive(rules)
+	v := indirect(fv)
+
+	for _, r := range own {
+		if r.name == "omitempty" {
+			if fv.IsZero() {
+				return nil
+			}
+			continue
+		}
+
+		// only values of interface fields can have a type the tags were not checked for
+		if v.IsValid() && !r.supports(v.Type()) {
+			return fmt.Errorf("validation: field %s: rule %q does not support %s", name, r.name, v.Type())
+		}
+
+		msg, args, ok := r.check(fv)
+		if !ok {
+			problems.Add(name, msg, args...)
+			return nil
+		}
+	}
+
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.Kind() == reflect.Struct && !isOpaqueStruct(v.Type()) {
+		return validateStruct(v, name+".", problems)
+	}
+
+	if !dive {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), name+"["+strconv.Itoa(i)+"]", elem, problems); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			key := formatKey(iter.Key())
+			if err := validateValue(iter.Value(), name+"["+key+"]", elem, problems); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// splitDive splits rules at the dive rule into rules for the value and rules for its elements.
+func splitDive(rules []rule) (own, elem []rule, dive bool) {
+	for i, r := range rules {
+		if r.name == "dive" {
+			return rules[:i], rules[i+1:], true
+		}
+	}
+	return rules, nil, false
+}
+
+// fieldName returns the problem key of a field, preferring its json and schema names.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "schema"} {
+		if tag := field.Tag.Get(key); tag != "" {
+			name, _, _ := strings.Cut(tag, ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
+// indirect dereferences pointers and interfaces, returning an invalid value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isOpaqueStruct reports whether a struct type is a value type that should not be
+// descended into, such as time.Time.
+func isOpaqueStruct(t reflect.Type) bool {
+	return t.PkgPath() == "time" || t.PkgPath() == "mime/multipart"
+}
+
+// formatKey formats a map key for use in a problem key.
+func formatKey(k reflect.Value) string {
+	return fmt.Sprint(k.Interface())
+}
@@ -1,10 +1,22 @@
 package muxo
 
-import "context"
+import (
+	"context"
+	"github.com/up1io/muxo/validation"
+)
 
 // Validator is an object that can be validated.
 type Validator interface {
 	// Valid checks the object and returns any problems.
 	// If len(problems) == 0 then the object is valid.
+	// The problem descriptions are used as message ids and translated for the request's language.
 	Valid(ctx context.Context) (problems map[string]string)
 }
+
+// MessageValidator is an object that reports problems as message ids with template parameters.
+// DecodeValid translates them for the request's language.
+type MessageValidator interface {
+	// ValidMessages checks the object and returns any problems.
+	// If len(problems) == 0 then the object is valid.
+	ValidMessages(ctx context.Context) (problems validation.Problems)
+}